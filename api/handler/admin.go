@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"example.com/auth-service-go/api/model"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"github.com/go-chi/chi"
+)
+
+//InitAdminRoutes initializes /admin subrouter, requests are throttled by limiter unless it is nil.
+func (h *Handler) InitAdminRoutes(auth *service.AuthService, limiter *service.RateLimiter) {
+	h.Router.Route("/admin", func(r chi.Router) {
+		if limiter != nil {
+			r.Use(rateLimit(h.Router, limiter))
+		}
+		r.Get("/tokens", findTokens(auth))
+		r.Delete("/tokens", revokeTokens(auth))
+		r.Put("/not_before", setNotBefore(auth))
+		r.Get("/audit", findAuditEvents(auth))
+		r.Get("/audit/export", exportAuditEvents(auth))
+	})
+}
+
+func findTokens(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := tokenFilter(query)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+		offset, err := queryInt(query, "offset")
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+		limit, err := queryInt(query, "limit")
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		page, err := auth.FindTokens(r.Context(), credentials(r), filter, offset, limit)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		now := auth.Now()
+		result := model.TokenList{Tokens: make([]model.AdminToken, 0, len(page.Tokens)), NextOffset: page.NextOffset}
+		for _, refreshToken := range page.Tokens {
+			sessionID := refreshToken.SessionID
+			if sessionID == "" {
+				sessionID = refreshToken.UUID
+			}
+			result.Tokens = append(result.Tokens, model.AdminToken{
+				ID:        refreshToken.UUID,
+				UserID:    refreshToken.UserID.String(),
+				ClientID:  refreshToken.ClientID,
+				SessionID: sessionID,
+				Scope:     entity.FormatScope(refreshToken.Scopes),
+				Status:    refreshToken.Status(now),
+				IssuedAt:  refreshToken.RotatedAt,
+				ExpiresAt: refreshToken.ExpiresAt,
+				UserAgent: refreshToken.UserAgent,
+				IP:        refreshToken.IP,
+			})
+		}
+		respondWithJSON("data", result, http.StatusOK, w)
+	}
+}
+
+func revokeTokens(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := tokenFilter(r.URL.Query())
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		deleted, err := auth.RevokeTokens(withDevice(r), credentials(r), filter)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		respondWithJSON("data", model.RevokedTokens{Deleted: deleted}, http.StatusOK, w)
+	}
+}
+
+func setNotBefore(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		watermark := &model.NotBefore{}
+		err := json.NewDecoder(r.Body).Decode(watermark)
+		if err != nil {
+			respondWithProblem(problemInvalidRequest, "Error parsing not before watermark", w, r)
+			return
+		}
+		var userID entity.UserID
+		if watermark.UserID != "" {
+			userID, err = entity.ParseUserID(watermark.UserID)
+			if err != nil {
+				respondWithError(err, w, r)
+				return
+			}
+		}
+		var notBefore time.Time
+		if watermark.NotBefore != 0 {
+			notBefore = time.Unix(watermark.NotBefore, 0)
+		}
+
+		notBefore, err = auth.SetNotBefore(withDevice(r), credentials(r), userID, notBefore)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		respondWithJSON("data", model.NotBefore{UserID: userID.String(), NotBefore: notBefore.Unix()}, http.StatusOK, w)
+	}
+}
+
+func findAuditEvents(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := auditFilter(query)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+		offset, err := queryInt(query, "offset")
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+		limit, err := queryInt(query, "limit")
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		page, err := auth.FindAuditEvents(r.Context(), credentials(r), filter, offset, limit)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		result := model.AuditEventList{Events: make([]model.AuditEvent, 0, len(page.Events)), NextOffset: page.NextOffset}
+		for _, event := range page.Events {
+			result.Events = append(result.Events, auditEvent(event))
+		}
+		respondWithJSON("data", result, http.StatusOK, w)
+	}
+}
+
+//exportAuditEvents streams audit events matching the filter as newline delimited JSON.
+//Once streaming has started errors can not be reported in status, so they are logged and the stream is cut short.
+//Write deadline is extended for each event, so export is not limited by server WriteTimeout.
+func exportAuditEvents(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := auditFilter(r.URL.Query())
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		started := false
+		err = auth.ExportAuditEvents(r.Context(), credentials(r), filter, func(event entity.AuditEvent) error {
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+			extendWriteDeadline(r, exportWriteTimeout)
+			return encoder.Encode(auditEvent(event))
+		})
+		switch {
+		case err != nil && !started:
+			respondWithError(err, w, r)
+		case err != nil:
+			log.Printf("Export of audit events was interrupted: %s", err.Error())
+		case !started:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+}
+
+//auditEvent converts audit event into it`s api representation.
+func auditEvent(event entity.AuditEvent) model.AuditEvent {
+	result := model.AuditEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		Time:      event.Time,
+		Actor:     event.Actor.String(),
+		UserID:    event.UserID.String(),
+		ClientID:  event.ClientID,
+		SessionID: event.SessionID,
+		TokenIDs:  event.TokenIDs,
+		Count:     event.Count,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Result:    event.Result,
+	}
+	if event.Filter != nil {
+		result.Filter = &model.AuditTokenFilter{
+			IssuedAfter:  event.Filter.IssuedAfter,
+			IssuedBefore: event.Filter.IssuedBefore,
+			Status:       event.Filter.Status,
+		}
+	}
+	return result
+}
+
+//auditFilter parses audit event filter from query parameters.
+func auditFilter(query url.Values) (entity.AuditFilter, error) {
+	filter := entity.AuditFilter{
+		Type:     query.Get("type"),
+		ClientID: query.Get("client_id"),
+	}
+	var err error
+	if filter.UserID, err = queryUserID(query, "user_id"); err != nil {
+		return filter, err
+	}
+	if filter.Actor, err = queryUserID(query, "actor"); err != nil {
+		return filter, err
+	}
+	if filter.After, err = queryTime(query, "after"); err != nil {
+		return filter, err
+	}
+	if filter.Before, err = queryTime(query, "before"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+//tokenFilter parses refresh token filter from query parameters.
+func tokenFilter(query url.Values) (entity.TokenFilter, error) {
+	filter := entity.TokenFilter{
+		ClientID:  query.Get("client_id"),
+		SessionID: query.Get("session_id"),
+		Status:    query.Get("status"),
+	}
+	if raw := query.Get("user_id"); raw != "" {
+		userID, err := entity.ParseUserID(raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.UserID = userID
+	}
+	var err error
+	if filter.IssuedAfter, err = queryTime(query, "issued_after"); err != nil {
+		return filter, err
+	}
+	if filter.IssuedBefore, err = queryTime(query, "issued_before"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+//queryTime parses time query parameter given as RFC 3339 time or unix seconds, it is zero if parameter is absent.
+func queryTime(query url.Values, name string) (time.Time, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, &entity.ArgumentError{Message: "Parameter " + name + " must be RFC 3339 time or unix seconds"}
+	}
+	return t, nil
+}
+
+//queryUserID parses user id query parameter, it is empty if parameter is absent.
+func queryUserID(query url.Values, name string) (entity.UserID, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return "", nil
+	}
+	return entity.ParseUserID(raw)
+}
+
+//queryInt parses integer query parameter, it is zero if parameter is absent.
+func queryInt(query url.Values, name string) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, &entity.ArgumentError{Message: "Parameter " + name + " must be an integer"}
+	}
+	return n, nil
+}
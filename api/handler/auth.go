@@ -3,200 +3,266 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"net"
 	"net/http"
 
 	"example.com/auth-service-go/api/model"
 	"example.com/auth-service-go/internal/entity"
-	"example.com/auth-service-go/internal/repository"
+	"example.com/auth-service-go/internal/service"
 	"github.com/go-chi/chi"
 )
 
-//InitAuthRoutes initializes /auth subrouter
-func (h *Handler) InitAuthRoutes(repo repository.Token) {
+//InitAuthRoutes initializes /auth subrouter, requests are throttled by limiter unless it is nil.
+func (h *Handler) InitAuthRoutes(auth *service.AuthService, limiter *service.RateLimiter) {
 	h.Router.Route("/auth", func(r chi.Router) {
-		r.Get("/user/{userID}", get(h.Context, repo))
-		r.Post("/tokens/refresh", refreshTokens(h.Context, repo))
-		r.Delete("/refresh", deleteRefreshToken(h.Context, repo))
-		r.Delete("/user/refresh", deleteUserRefreshTokens(h.Context, repo))
+		if limiter != nil {
+			r.Use(rateLimit(h.Router, limiter))
+		}
+		r.Get("/user/{userID}", get(auth, h.CookieMode))
+		r.Post("/tokens/refresh", refreshTokens(auth, h.CookieMode))
+		r.Delete("/refresh", deleteRefreshToken(auth, h.CookieMode))
+		r.Delete("/user/refresh", deleteUserRefreshTokens(auth))
+		r.Post("/register", register(auth))
+		r.Post("/login", login(auth, h.CookieMode))
+		r.Put("/password", changePassword(auth))
+		r.Get("/sessions", listSessions(auth))
+		r.Delete("/sessions/{sessionID}", deleteSession(auth))
 	})
 }
 
-func get(ctx context.Context, repo repository.Token) http.HandlerFunc {
+func get(auth *service.AuthService, cookieMode bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "userID")
-		if id == "" {
-			respondWithError("User id is empty", http.StatusBadRequest, w)
-			return
-		}
-
-		tokenPair, err := entity.CreateTokenPair(id)
+		userID, err := entity.ParseUserID(chi.URLParam(r, "userID"))
 		if err != nil {
-			respondWithError("Error creating tokens", http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		respTokens := model.TokenPair{
-			AccessToken: tokenPair.AccessToken.Token,
-			//Convert refresh token into base64 string before sending it to the user.
-			RefreshToken: entity.EncodeToken64(tokenPair.RefreshToken.Token),
-		}
-
-		err = repo.Insert(ctx, tokenPair)
+		tokenPair, err := auth.Issue(withDevice(r), credentials(r), userID, entity.ParseScope(r.URL.Query().Get("scope")))
 		if err != nil {
-			respondWithError("Error inserting tokens into DB", http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		respondWithJSON("data", respTokens, http.StatusOK, w)
+		respondWithTokenPair(tokenPair, wantsCookie(cookieMode, r), w, r)
 	}
 }
 
-func refreshTokens(ctx context.Context, repo repository.Token) http.HandlerFunc {
+func refreshTokens(auth *service.AuthService, cookieMode bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tokens := &model.TokenPair{}
 		err := json.NewDecoder(r.Body).Decode(tokens)
 		if err != nil {
-			respondWithError("Error parsing pair of tokens", http.StatusBadRequest, w)
-			return
-		}
-		if tokens.AccessToken == "" {
-			respondWithError("Access token is empty", http.StatusBadRequest, w)
+			respondWithProblem(problemInvalidRequest, "Error parsing pair of tokens", w, r)
 			return
 		}
 		if tokens.RefreshToken == "" {
-			respondWithError("Refresh token is empty", http.StatusBadRequest, w)
-			return
+			refreshToken, ok := cookieRefreshToken(cookieMode, r)
+			if !ok {
+				respondWithProblem(problemCSRF, "CSRF token does not match", w, r)
+				return
+			}
+			tokens.RefreshToken = refreshToken
 		}
 
-		refreshToken, err := entity.DecodeToken64(tokens.RefreshToken)
+		tokenPair, err := auth.Refresh(withDevice(r), tokens.AccessToken, tokens.RefreshToken, entity.ParseScope(tokens.Scope))
 		if err != nil {
-			respondWithError(fmt.Sprintf("Error decoding refresh token %s", tokens.RefreshToken), http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		claimsRefreshToken, err := entity.ParseRefreshToken(refreshToken)
+		respondWithTokenPair(tokenPair, wantsCookie(cookieMode, r), w, r)
+	}
+}
+
+func deleteRefreshToken(auth *service.AuthService, cookieMode bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestRefreshToken := model.RefreshToken{}
+		err := json.NewDecoder(r.Body).Decode(&requestRefreshToken)
 		if err != nil {
-			respondWithError(fmt.Sprintf("Error parsing refresh token %s. %s", refreshToken, err.Error()), http.StatusInternalServerError, w)
+			respondWithProblem(problemInvalidRequest, "Error parsing refresh token", w, r)
 			return
 		}
-		claimsAccessToken, err := entity.ParseAccessToken(tokens.AccessToken)
+		fromCookie := false
+		if requestRefreshToken.Token == "" {
+			refreshToken, ok := cookieRefreshToken(cookieMode, r)
+			if !ok {
+				respondWithProblem(problemCSRF, "CSRF token does not match", w, r)
+				return
+			}
+			requestRefreshToken.Token, fromCookie = refreshToken, refreshToken != ""
+		}
+
+		err = auth.Revoke(withDevice(r), credentials(r), requestRefreshToken.Token)
 		if err != nil {
-			respondWithError(fmt.Sprintf("Error parsing access token %s. %s", refreshToken, err.Error()), http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
-		//Check bind between access and refresh token.
-		if claimsAccessToken.Refresh_uuid != claimsRefreshToken.UUID {
-			respondWithError("Access token does not belong to refresh token", http.StatusInternalServerError, w)
-			return
+		if fromCookie {
+			clearRefreshCookies(w)
 		}
 
-		isRefreshTokenInDB := repo.IsRefreshTokenInDB(ctx, claimsRefreshToken.UUID)
-		if !isRefreshTokenInDB {
-			respondWithError("There is no such refresh token", http.StatusNotFound, w)
-			return
-		}
+		respondWithJSON("message", "Refresh token was successfully deleted", http.StatusOK, w)
+	}
+}
 
-		err = repo.RefreshTokenSetIsUsed(ctx, claimsAccessToken.Refresh_uuid)
+func deleteUserRefreshTokens(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := &model.User{}
+		err := json.NewDecoder(r.Body).Decode(u)
 		if err != nil {
-			respondWithError(err.Error(), http.StatusInternalServerError, w)
+			respondWithProblem(problemInvalidRequest, "Error parsing user id", w, r)
 			return
 		}
 
-		tokenPair, err := entity.CreateTokenPair(claimsRefreshToken.User_id)
+		userID, err := entity.ParseUserID(u.UserID)
 		if err != nil {
-			respondWithError("Error creating tokens", http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
-		respTokens := model.TokenPair{
-			AccessToken: tokenPair.AccessToken.Token,
-			//Convert refresh token into base64 string before sending it to the user.
-			RefreshToken: entity.EncodeToken64(tokenPair.RefreshToken.Token),
-		}
 
-		err = repo.Insert(ctx, tokenPair)
+		err = auth.RevokeAll(withDevice(r), credentials(r), userID)
 		if err != nil {
-			respondWithError("Error inserting tokens into DB", http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		respondWithJSON("data", respTokens, http.StatusOK, w)
+		respondWithJSON("message", "User refresh tokens was successfully deleted", http.StatusOK, w)
 	}
 }
 
-func deleteRefreshToken(ctx context.Context, repo repository.Token) http.HandlerFunc {
+func register(auth *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		requestRefreshToken := model.RefreshToken{}
-		err := json.NewDecoder(r.Body).Decode(&requestRefreshToken)
+		registration := &model.Registration{}
+		err := json.NewDecoder(r.Body).Decode(registration)
 		if err != nil {
-			respondWithError("Error parsing refresh token", http.StatusBadRequest, w)
+			respondWithProblem(problemInvalidRequest, "Error parsing registration", w, r)
 			return
 		}
-		if requestRefreshToken.Token == "" {
-			respondWithError("Refresh token is empty", http.StatusBadRequest, w)
+
+		userID, err := auth.Register(r.Context(), registration.Password)
+		if err != nil {
+			respondWithError(err, w, r)
 			return
 		}
 
-		refreshToken, err := entity.DecodeToken64(requestRefreshToken.Token)
+		respondWithJSON("data", model.User{UserID: userID.String()}, http.StatusCreated, w)
+	}
+}
+
+func login(auth *service.AuthService, cookieMode bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		creds := &model.Credentials{}
+		err := json.NewDecoder(r.Body).Decode(creds)
 		if err != nil {
-			respondWithError(fmt.Sprintf("Error decoding refresh token %s", requestRefreshToken.Token), http.StatusInternalServerError, w)
+			respondWithProblem(problemInvalidRequest, "Error parsing credentials", w, r)
 			return
 		}
-		claimsRefreshToken, err := entity.ParseRefreshToken(refreshToken)
+
+		userID, err := entity.ParseUserID(creds.UserID)
 		if err != nil {
-			respondWithError(fmt.Sprintf("Error parsing refresh token: %s. %s", refreshToken, err.Error()), http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		userID := claimsRefreshToken.User_id
-		isUserInDB := repo.IsUserInDB(ctx, userID)
-		if !isUserInDB {
-			respondWithError("There is no such user", http.StatusNotFound, w)
+		tokenPair, err := auth.Login(withDevice(r), userID, creds.Password, entity.ParseScope(creds.Scope))
+		if err != nil {
+			respondWithError(err, w, r)
 			return
 		}
 
-		refreshTokenUUID := claimsRefreshToken.UUID
-		isRefreshTokenInDB := repo.IsRefreshTokenInDB(ctx, refreshTokenUUID)
-		if !isRefreshTokenInDB {
-			respondWithError("There is no such refresh token", http.StatusNotFound, w)
+		respondWithTokenPair(tokenPair, wantsCookie(cookieMode, r), w, r)
+	}
+}
+
+func changePassword(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		change := &model.PasswordChange{}
+		err := json.NewDecoder(r.Body).Decode(change)
+		if err != nil {
+			respondWithProblem(problemInvalidRequest, "Error parsing password change", w, r)
 			return
 		}
 
-		err = repo.DeleteRefreshToken(ctx, userID, refreshTokenUUID)
+		err = auth.ChangePassword(withDevice(r), credentials(r), change.OldPassword, change.NewPassword)
 		if err != nil {
-			respondWithError("Error deleting refresh token", http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		respondWithJSON("message", "Refresh token was successfully deleted", http.StatusOK, w)
+		respondWithJSON("message", "Password was successfully changed", http.StatusOK, w)
 	}
 }
 
-func deleteUserRefreshTokens(ctx context.Context, repo repository.Token) http.HandlerFunc {
+func listSessions(auth *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		u := &model.User{}
-		err := json.NewDecoder(r.Body).Decode(u)
+		sessions, err := auth.Sessions(r.Context(), credentials(r))
 		if err != nil {
-			respondWithError("Error parsing user id", http.StatusBadRequest, w)
-			return
-		}
-		if u.UserID == "" {
-			respondWithError("User id is empty", http.StatusBadRequest, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		isUserInDB := repo.IsUserInDB(ctx, u.UserID)
-		if !isUserInDB {
-			respondWithError("There is no such user", http.StatusNotFound, w)
-			return
+		result := make([]model.Session, 0, len(sessions))
+		for _, session := range sessions {
+			result = append(result, model.Session{
+				ID:        session.ID,
+				ClientID:  session.ClientID,
+				UserAgent: session.Device.UserAgent,
+				IP:        session.Device.IP,
+				CreatedAt: session.CreatedAt,
+				RotatedAt: session.RotatedAt,
+				ExpiresAt: session.ExpiresAt,
+				Current:   session.Current,
+			})
 		}
+		respondWithJSON("data", result, http.StatusOK, w)
+	}
+}
 
-		err = repo.DeleteUserRefreshTokens(ctx, u.UserID)
+func deleteSession(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := auth.RevokeSession(withDevice(r), credentials(r), chi.URLParam(r, "sessionID"))
 		if err != nil {
-			respondWithError("Error deleting refresh tokens", http.StatusInternalServerError, w)
+			respondWithError(err, w, r)
 			return
 		}
 
-		respondWithJSON("message", "User refresh tokens was successfully deleted", http.StatusOK, w)
+		respondWithJSON("message", "Session was successfully deleted", http.StatusOK, w)
+	}
+}
+
+//withDevice returns ctx of the request carrying the device it was sent from.
+func withDevice(r *http.Request) context.Context {
+	return service.ContextWithDevice(r.Context(), service.Device{UserAgent: r.UserAgent(), IP: remoteIP(r)})
+}
+
+//remoteIP returns IP address of the client resolved by Handler.clientIP, or the address of the connection.
+func remoteIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return connectionIP(r)
+}
+
+//connectionIP returns IP address of the other end of the connection.
+func connectionIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+//credentials extracts caller credentials from request headers.
+func credentials(r *http.Request) service.Credentials {
+	return service.CredentialsFromHeaders(r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
+}
+
+//toTokenPair converts pair of tokens into api JSON representation.
+func toTokenPair(tokenPair *service.TokenPair) model.TokenPair {
+	return model.TokenPair{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		Scope:        entity.FormatScope(tokenPair.Scopes),
 	}
 }
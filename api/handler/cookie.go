@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/auth-service-go/internal/service"
+)
+
+//Names of cookies and headers of cookie mode.
+const (
+	refreshTokenCookie = "refresh_token"
+	//csrfTokenCookie is readable by scripts of the client, which echo it in csrfTokenHeader (double-submit cookie).
+	csrfTokenCookie = "csrf_token"
+	csrfTokenHeader = "X-CSRF-Token"
+	//tokenDeliveryHeader set to "cookie" asks for refresh token to be delivered in cookie rather than in JSON body.
+	tokenDeliveryHeader = "X-Token-Delivery"
+)
+
+//csrfTokenLength is a length of random CSRF token in bytes.
+const csrfTokenLength = 32
+
+//refreshTokenPaths are the only paths refresh token cookie is sent to, the cookie is set for each of them.
+var refreshTokenPaths = []string{"/auth/tokens/refresh", "/auth/refresh"}
+
+//wantsCookie reports whether client asked for refresh token in cookie and cookie mode is enabled.
+func wantsCookie(cookieMode bool, r *http.Request) bool {
+	return cookieMode && strings.EqualFold(r.Header.Get(tokenDeliveryHeader), "cookie")
+}
+
+//respondWithTokenPair responds with pair of tokens. If inCookie is true, refresh token is set as HttpOnly cookie
+//out of reach of scripts and is left out of JSON body.
+func respondWithTokenPair(tokenPair *service.TokenPair, inCookie bool, w http.ResponseWriter, r *http.Request) {
+	pair := toTokenPair(tokenPair)
+	if inCookie && tokenPair.RefreshToken != "" {
+		if err := setRefreshCookies(tokenPair, w); err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+		pair.RefreshToken = ""
+	}
+	respondWithJSON("data", pair, http.StatusOK, w)
+}
+
+//setRefreshCookies sets refresh token cookie and a fresh CSRF token cookie, both expire together with refresh token.
+func setRefreshCookies(tokenPair *service.TokenPair, w http.ResponseWriter) error {
+	csrf := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+	expires := time.Unix(tokenPair.RefreshTokenExpiresAt, 0)
+	for _, path := range refreshTokenPaths {
+		http.SetCookie(w, &http.Cookie{
+			Name:     refreshTokenCookie,
+			Value:    tokenPair.RefreshToken,
+			Path:     path,
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfTokenCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(csrf),
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+//clearRefreshCookies removes refresh token and CSRF token cookies.
+func clearRefreshCookies(w http.ResponseWriter) {
+	for _, path := range refreshTokenPaths {
+		http.SetCookie(w, &http.Cookie{Name: refreshTokenCookie, Path: path, MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode})
+	}
+	http.SetCookie(w, &http.Cookie{Name: csrfTokenCookie, Path: "/", MaxAge: -1, Secure: true, SameSite: http.SameSiteStrictMode})
+}
+
+//cookieRefreshToken returns refresh token from cookie, it is empty if client did not ask for cookie delivery
+//as wantsCookie reports, or there is no cookie.
+//Browser sends the cookie with any request to the path, so the request must also prove it was made by the client
+//by echoing CSRF token cookie in csrfTokenHeader, which other sites can neither read nor set.
+//It reports false if the check failed.
+func cookieRefreshToken(cookieMode bool, r *http.Request) (string, bool) {
+	if !wantsCookie(cookieMode, r) {
+		return "", true
+	}
+	refreshToken, err := r.Cookie(refreshTokenCookie)
+	if err != nil {
+		return "", true
+	}
+	csrf, err := r.Cookie(csrfTokenCookie)
+	header := r.Header.Get(csrfTokenHeader)
+	if err != nil || csrf.Value == "" || subtle.ConstantTimeCompare([]byte(csrf.Value), []byte(header)) != 1 {
+		return "", false
+	}
+	return refreshToken.Value, true
+}
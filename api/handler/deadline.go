@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+//exportWriteTimeout bounds writing of each event of streamed export instead of server WriteTimeout.
+const exportWriteTimeout = 5 * time.Second
+
+type connKey struct{}
+
+//ConnContext keeps connection in ctx of it`s requests, so streaming handlers can extend write deadline.
+//It is meant to be used as http.Server ConnContext.
+func (h *Handler) ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+//extendWriteDeadline moves write deadline of the request connection d from now.
+//Server sets WriteTimeout once when request is read, so it would cut long responses short.
+//It does nothing if connection was not kept by ConnContext.
+func extendWriteDeadline(r *http.Request, d time.Duration) {
+	if c, ok := r.Context().Value(connKey{}).(net.Conn); ok {
+		c.SetWriteDeadline(time.Now().Add(d))
+	}
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"example.com/auth-service-go/api/openapi"
+)
+
+//InitDocsRoutes initializes route serving OpenAPI document of the service.
+func (h *Handler) InitDocsRoutes(doc *openapi.Document) {
+	h.Router.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	})
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"example.com/auth-service-go/api/model"
+	"example.com/auth-service-go/internal/entity"
+)
+
+//statusClientClosedRequest is a non-standard status of responses to clients which have gone before the request was done.
+const statusClientClosedRequest = 499
+
+//problemTypeBase is a prefix of problem type URIs. Problem type is problemTypeBase followed by error code.
+const problemTypeBase = "/problems/"
+
+//problem is an entry of the error catalogue.
+type problem struct {
+	//code is a stable machine readable error code. It must never change once published.
+	code   string
+	title  string
+	status int
+	//bearerError is an error code of WWW-Authenticate challenge as described in RFC 6750 section 3.1.
+	bearerError string
+}
+
+//Error catalogue. Every error response of the service has one of these codes.
+var (
+	//problemInvalidRequest is reported when request body or parameters can not be parsed or are missing.
+	problemInvalidRequest = problem{"invalid_request", "Request is not valid", http.StatusBadRequest, ""}
+	//problemMalformedToken is reported when provided token can not be decoded or parsed.
+	problemMalformedToken = problem{"malformed_token", "Token is malformed", http.StatusBadRequest, ""}
+	//problemInvalidToken is reported when token signature or claims are not valid.
+	problemInvalidToken = problem{"invalid_token", "Token is not valid", http.StatusUnauthorized, "invalid_token"}
+	//problemTokenExpired is reported when token is past its expiration time.
+	problemTokenExpired = problem{"token_expired", "Token is expired", http.StatusUnauthorized, "invalid_token"}
+	//problemTokenMismatch is reported when access token was not issued together with refresh token.
+	problemTokenMismatch = problem{"token_mismatch", "Access token does not belong to refresh token", http.StatusUnauthorized, "invalid_token"}
+	//problemUnauthenticated is reported when caller credentials are missing or not valid.
+	problemUnauthenticated = problem{"unauthenticated", "Authentication is required", http.StatusUnauthorized, ""}
+	//problemForbidden is reported when caller is not allowed to act on behalf of the user.
+	problemForbidden = problem{"forbidden", "Operation is not allowed", http.StatusForbidden, "insufficient_scope"}
+	//problemCSRF is reported when request authenticated by refresh token cookie does not echo CSRF token cookie.
+	problemCSRF = problem{"csrf_token_mismatch", "CSRF token is missing or does not match", http.StatusBadRequest, "invalid_request"}
+	//problemInvalidScope is reported when requested scope exceeds scopes granted to refresh token.
+	problemInvalidScope = problem{"invalid_scope", "Requested scope is not allowed", http.StatusForbidden, "insufficient_scope"}
+	//problemTokenNotFound is reported when there is no such refresh token.
+	problemTokenNotFound = problem{"token_not_found", "Refresh token not found", http.StatusNotFound, ""}
+	//problemUserNotFound is reported when there is no such user.
+	problemUserNotFound = problem{"user_not_found", "User not found", http.StatusNotFound, ""}
+	//problemSessionNotFound is reported when the user has no such session.
+	problemSessionNotFound = problem{"session_not_found", "Session not found", http.StatusNotFound, ""}
+	//problemClientNotFound is reported when there is no such OAuth 2.0 client.
+	problemClientNotFound = problem{"client_not_found", "Client not found", http.StatusNotFound, ""}
+	//problemCodeNotFound is reported when there is no such device user code.
+	problemCodeNotFound = problem{"code_not_found", "Code not found", http.StatusNotFound, ""}
+	//problemCodeExpired is reported when device user code is past its expiration time.
+	problemCodeExpired = problem{"code_expired", "Code is expired", http.StatusGone, ""}
+	//problemUserExists is reported when user with the same id is already registered.
+	problemUserExists = problem{"user_exists", "User already exists", http.StatusConflict, ""}
+	//problemTokenUsed is reported when refresh token has already been used.
+	problemTokenUsed = problem{"token_used", "Refresh token has already been used", http.StatusConflict, ""}
+	//problemCodeUsed is reported when device authorization request has already been approved or denied.
+	problemCodeUsed = problem{"code_used", "Code has already been used", http.StatusConflict, ""}
+	//problemSessionLimit is reported when the user has too many active sessions to start a new one.
+	problemSessionLimit = problem{"session_limit", "Too many active sessions", http.StatusConflict, ""}
+	//problemRateLimited is reported when the client or the user made too many requests, Retry-After tells when to retry.
+	problemRateLimited = problem{"rate_limited", "Too many requests", http.StatusTooManyRequests, ""}
+	//problemOverloaded is reported when the service can not complete the operation in time under load.
+	problemOverloaded = problem{"overloaded", "Service is overloaded", http.StatusServiceUnavailable, ""}
+	//problemStorageUnavailable is reported when storage can not complete the operation.
+	problemStorageUnavailable = problem{"storage_unavailable", "Storage is unavailable", http.StatusServiceUnavailable, ""}
+	//problemRequestCanceled is reported when the client has gone before the request was done, it is only seen in logs.
+	problemRequestCanceled = problem{"request_canceled", "Request was canceled", statusClientClosedRequest, ""}
+	//problemDeadlineExceeded is reported when the request could not be done before it`s deadline.
+	problemDeadlineExceeded = problem{"deadline_exceeded", "Request timed out", http.StatusServiceUnavailable, ""}
+	//problemInternal is reported for any unexpected error.
+	problemInternal = problem{"internal_error", "Internal server error", http.StatusInternalServerError, ""}
+)
+
+//errorProblems maps domain errors onto catalogue entries.
+var errorProblems = []struct {
+	err     error
+	problem problem
+}{
+	{entity.ErrInvalidArgument, problemInvalidRequest},
+	{entity.ErrMalformedToken, problemMalformedToken},
+	{entity.ErrInvalidSignature, problemInvalidToken},
+	{entity.ErrInvalidToken, problemInvalidToken},
+	{entity.ErrTokenExpired, problemTokenExpired},
+	{entity.ErrTokenMismatch, problemTokenMismatch},
+	{entity.ErrUnauthenticated, problemUnauthenticated},
+	{entity.ErrForbidden, problemForbidden},
+	{entity.ErrInvalidScope, problemInvalidScope},
+	{entity.ErrTokenNotFound, problemTokenNotFound},
+	{entity.ErrUserNotFound, problemUserNotFound},
+	{entity.ErrUserExists, problemUserExists},
+	{entity.ErrClientNotFound, problemClientNotFound},
+	{entity.ErrSessionNotFound, problemSessionNotFound},
+	{entity.ErrTokenUsed, problemTokenUsed},
+	{entity.ErrCodeNotFound, problemCodeNotFound},
+	{entity.ErrCodeExpired, problemCodeExpired},
+	{entity.ErrCodeUsed, problemCodeUsed},
+	{entity.ErrSessionLimit, problemSessionLimit},
+	{entity.ErrOverloaded, problemOverloaded},
+	{entity.ErrStorageUnavailable, problemStorageUnavailable},
+	{context.Canceled, problemRequestCanceled},
+	{context.DeadlineExceeded, problemDeadlineExceeded},
+}
+
+//problemFromError returns catalogue entry and client safe detail for the given error.
+//Unknown errors are reported as internal errors without details.
+func problemFromError(err error) (problem, string) {
+	var argErr *entity.ArgumentError
+	if errors.As(err, &argErr) {
+		return problemInvalidRequest, argErr.Message
+	}
+	for _, e := range errorProblems {
+		if errors.Is(err, e.err) {
+			return e.problem, e.err.Error()
+		}
+	}
+	return problemInternal, ""
+}
+
+//respondWithError is a helper for handling problem+json responses with domain errors.
+func respondWithError(err error, w http.ResponseWriter, r *http.Request) {
+	log.Println(err.Error())
+	p, detail := problemFromError(err)
+	respondWithProblem(p, detail, w, r)
+}
+
+//respondWithProblem is a helper for handling problem+json responses.
+func respondWithProblem(p problem, detail string, w http.ResponseWriter, r *http.Request) {
+	//See RFC 6750 section 3.
+	switch {
+	case p.bearerError != "":
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="auth-service", error=%q, error_description=%q`, p.bearerError, p.title))
+	case p.status == http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="auth-service"`)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.status)
+
+	json.NewEncoder(w).Encode(model.Problem{
+		Type:     problemTypeBase + p.code,
+		Title:    p.title,
+		Status:   p.status,
+		Detail:   detail,
+		Instance: r.URL.RequestURI(),
+		Code:     p.code,
+	})
+}
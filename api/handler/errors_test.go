@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+func TestProblemFromError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   string
+		status int
+		oauth  oauthError
+	}{
+		{"argument error", &entity.ArgumentError{Message: "User id is empty"}, "invalid_request", http.StatusBadRequest, oauthInvalidRequest},
+		{"wrapped domain error", fmt.Errorf("%w: connection refused", entity.ErrStorageUnavailable), "storage_unavailable", http.StatusServiceUnavailable, oauthTemporarilyUnavailable},
+		{"client has gone", fmt.Errorf("Error hashing password: %w", context.Canceled), "request_canceled", statusClientClosedRequest, oauthRequestCanceled},
+		{"deadline exceeded", fmt.Errorf("Error hashing password: %w", context.DeadlineExceeded), "deadline_exceeded", http.StatusServiceUnavailable, oauthTemporarilyUnavailable},
+		{"unknown error", errors.New("unexpected"), "internal_error", http.StatusInternalServerError, oauthServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := problemFromError(tt.err)
+			if p.code != tt.code || p.status != tt.status {
+				t.Errorf("got problem %s %d, want %s %d", p.code, p.status, tt.code, tt.status)
+			}
+			if got, _ := oauthErrorFromError(tt.err); got != tt.oauth {
+				t.Errorf("got OAuth error %+v, want %+v", got, tt.oauth)
+			}
+		})
+	}
+}
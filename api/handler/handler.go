@@ -10,16 +10,25 @@ import (
 
 //Handler is a handler with nested router.
 type Handler struct {
-	Router  *chi.Mux
+	Router *chi.Mux
+	//Context is a base context of requests of the server. Handlers pass context of the request to the service,
+	//so work for the clients which have gone is canceled.
 	Context context.Context
+	//CookieMode allows browser clients to receive refresh tokens in HttpOnly cookies, it must be set before routes are initialized.
+	CookieMode bool
+	//TrustedProxies is a number of reverse proxies in front of the service, each of them appends address of it`s client
+	//to X-Forwarded-For. Zero ignores the header, so the address of the connection is the address of the client.
+	TrustedProxies int
 }
 
-//New creates new Handler with nested router.
+//New creates new Handler with nested router. Address of the client is resolved for every request of the router.
 func New(ctx context.Context, router *chi.Mux) *Handler {
-	return &Handler{
+	h := &Handler{
 		Router:  router,
 		Context: ctx,
 	}
+	router.Use(h.clientIP)
+	return h
 }
 
 //RespondWithJSON is a helper for handling json responses.
@@ -32,8 +41,3 @@ func respondWithJSON(message string, payload interface{}, statusCode int, w http
 
 	json.NewEncoder(w).Encode(jsonMap)
 }
-
-//RespondWithError is a helper for handling json responses with errors
-func respondWithError(payload interface{}, statusCode int, w http.ResponseWriter) {
-	respondWithJSON("error", payload, statusCode, w)
-}
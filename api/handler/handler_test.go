@@ -0,0 +1,368 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"example.com/auth-service-go/api/handler"
+	"example.com/auth-service-go/api/openapi"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"example.com/auth-service-go/internal/service/servicetest"
+	"github.com/go-chi/chi"
+)
+
+const (
+	issuer   = "https://auth.example"
+	adminKey = "admin key"
+	adminID  = "5d1c9c1e-3c4b-4f5e-9a77-1c2d3e4f5a6b"
+	password = "correct horse battery staple"
+	//redirectURI is the only redirect URI of the test client.
+	redirectURI = "https://client.example/callback"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("TOKEN_SECRET", "test token secret")
+	os.Exit(m.Run())
+}
+
+//apiTest sends requests to the router validated against the OpenAPI document
+//and keeps track of documented operations the requests were served by.
+type apiTest struct {
+	t         *testing.T
+	doc       *openapi.Document
+	router    *chi.Mux
+	exercised map[*openapi.Operation]bool
+}
+
+func newAPITest(t *testing.T) *apiTest {
+	repos := servicetest.New()
+	entity.UseWatermarks(repos.Watermarks)
+	t.Cleanup(func() { entity.UseWatermarks(nil) })
+	apiKeys, err := service.NewAPIKeyAuthenticator(adminKey + ":" + adminID + ":" + service.ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idTokenKey, err := entity.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := repos.AuthService(service.Authenticators{apiKeys, service.AccessTokenAuthenticator{Audience: issuer}},
+		service.WithIssuer(issuer), service.WithIDTokenKey(idTokenKey))
+
+	a := &apiTest{t: t, doc: openapi.New(), router: chi.NewRouter(), exercised: map[*openapi.Operation]bool{}}
+	a.router.Use(a.doc.Validator(func(r *http.Request, err error) {
+		t.Errorf("OpenAPI validation: %s", err.Error())
+	}))
+	h := handler.New(context.Background(), a.router)
+	h.CookieMode = true
+	h.InitAuthRoutes(auth, nil)
+	h.InitOAuthRoutes(auth, nil)
+	h.InitOIDCRoutes(auth)
+	h.InitAdminRoutes(auth, nil)
+	h.InitDocsRoutes(a.doc)
+	a.router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("App is running"))
+	})
+	if err := a.doc.CheckRoutes(a.router); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+//do sends request with given body and headers, given as name and value pairs, and checks status of the response.
+func (a *apiTest) do(method, target string, body io.Reader, want int, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	r := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	if op, ok := a.doc.Operation(method, r.URL.Path); ok {
+		a.exercised[op] = true
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	if w.Code != want {
+		a.t.Fatalf("%s %s: got status %d, want %d: %s", method, target, w.Code, want, w.Body.String())
+	}
+	return w
+}
+
+//json sends JSON encoded body and decodes data of the response into out unless it is nil.
+func (a *apiTest) json(method, target string, body interface{}, want int, out interface{}, headers ...string) {
+	a.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		reader = strings.NewReader(string(encoded))
+	}
+	w := a.do(method, target, reader, want, append(headers, "Content-Type", "application/json")...)
+	if out != nil {
+		a.decode(w, &struct{ Data interface{} }{out})
+	}
+}
+
+//form sends form encoded OAuth 2.0 request and decodes the response into out unless it is nil.
+func (a *apiTest) form(target string, values url.Values, want int, out interface{}, headers ...string) {
+	a.t.Helper()
+	w := a.do(http.MethodPost, target, strings.NewReader(values.Encode()), want,
+		append(headers, "Content-Type", "application/x-www-form-urlencoded")...)
+	if out != nil {
+		a.decode(w, out)
+	}
+}
+
+func (a *apiTest) decode(w *httptest.ResponseRecorder, out interface{}) {
+	a.t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(out); err != nil {
+		a.t.Fatalf("Error decoding response %q: %s", w.Body.String(), err.Error())
+	}
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+}
+
+//csrfTokenField matches CSRF token of the login form.
+var csrfTokenField = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+//loginForm returns hidden fields of the login form rendered into w together with it`s CSRF token cookie.
+func loginForm(t *testing.T, w *httptest.ResponseRecorder) (url.Values, *http.Cookie) {
+	t.Helper()
+	var csrfCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "authorize_csrf" {
+			csrfCookie = cookie
+		}
+	}
+	body := w.Body.String()
+	match := csrfTokenField.FindStringSubmatch(body)
+	if csrfCookie == nil || match == nil || match[1] != csrfCookie.Value {
+		t.Fatalf("got login form %q with cookies %v, want CSRF token in both", body, w.Result().Cookies())
+	}
+	form := url.Values{"csrf_token": {match[1]}}
+	for _, hidden := range regexp.MustCompile(`type="hidden" name="([^"]+)" value="([^"]*)"`).FindAllStringSubmatch(body, -1) {
+		form.Set(hidden[1], html.UnescapeString(hidden[2]))
+	}
+	return form, csrfCookie
+}
+
+func bearer(token string) string {
+	return "Bearer " + token
+}
+
+func basic(clientID, secret string) string {
+	credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(secret)
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+//TestRoutesMatchDocument exercises every documented operation and validates requests and responses against the document.
+func TestRoutesMatchDocument(t *testing.T) {
+	a := newAPITest(t)
+	admin := []string{"X-API-Key", adminKey}
+
+	a.do(http.MethodGet, "/", nil, http.StatusOK)
+	a.do(http.MethodGet, "/openapi.json", nil, http.StatusOK)
+	a.do(http.MethodGet, "/.well-known/openid-configuration", nil, http.StatusOK)
+	a.do(http.MethodGet, "/.well-known/jwks.json", nil, http.StatusOK)
+
+	//Users and their tokens.
+	var user struct {
+		UserID string `json:"user_id"`
+	}
+	a.json(http.MethodPost, "/auth/register", map[string]string{"password": password}, http.StatusCreated, &user)
+	credentials := map[string]string{"user_id": user.UserID, "password": password}
+	var login tokenPair
+	a.json(http.MethodPost, "/auth/login", credentials, http.StatusOK, &login)
+	a.json(http.MethodPost, "/auth/login", map[string]string{"user_id": user.UserID, "password": "wrong password"}, http.StatusUnauthorized, nil)
+	var issued, refreshed tokenPair
+	a.json(http.MethodGet, "/auth/user/"+user.UserID, nil, http.StatusOK, &issued, admin...)
+	a.json(http.MethodGet, "/auth/user/"+adminID, nil, http.StatusForbidden, nil, "Authorization", bearer(login.AccessToken))
+	a.json(http.MethodPost, "/auth/tokens/refresh", issued, http.StatusOK, &refreshed)
+	a.json(http.MethodPost, "/auth/tokens/refresh", issued, http.StatusConflict, nil)
+
+	var sessions []struct {
+		ID      string `json:"id"`
+		Current bool   `json:"current"`
+	}
+	a.json(http.MethodGet, "/auth/sessions", nil, http.StatusOK, &sessions, "Authorization", bearer(refreshed.AccessToken))
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+	for _, session := range sessions {
+		if !session.Current {
+			a.json(http.MethodDelete, "/auth/sessions/"+session.ID, nil, http.StatusOK, nil, "Authorization", bearer(refreshed.AccessToken))
+		}
+	}
+	a.json(http.MethodGet, "/auth/sessions", nil, http.StatusUnauthorized, nil)
+	a.json(http.MethodDelete, "/auth/refresh", map[string]string{"refresh_token": refreshed.RefreshToken}, http.StatusOK, nil,
+		"Authorization", bearer(refreshed.AccessToken))
+
+	//Browser client holds refresh token in cookie and echoes CSRF token.
+	w := a.do(http.MethodPost, "/auth/login", strings.NewReader(`{"user_id":"`+user.UserID+`","password":"`+password+`"}`), http.StatusOK,
+		"X-Token-Delivery", "cookie")
+	var refreshCookie, csrfCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		switch cookie.Name {
+		case "refresh_token":
+			refreshCookie = cookie
+		case "csrf_token":
+			csrfCookie = cookie
+		}
+	}
+	if refreshCookie == nil || csrfCookie == nil {
+		t.Fatalf("got cookies %v, want refresh and CSRF token cookies", w.Result().Cookies())
+	}
+	var cookieLogin tokenPair
+	a.decode(w, &struct{ Data interface{} }{&cookieLogin})
+	cookies := refreshCookie.Name + "=" + refreshCookie.Value + "; " + csrfCookie.Name + "=" + csrfCookie.Value
+	body := `{"access_token":"` + cookieLogin.AccessToken + `"}`
+	a.do(http.MethodPost, "/auth/tokens/refresh", strings.NewReader(body), http.StatusBadRequest,
+		"Cookie", cookies, "X-Token-Delivery", "cookie")
+	a.do(http.MethodPost, "/auth/tokens/refresh", strings.NewReader(body), http.StatusOK,
+		"Cookie", cookies, "X-CSRF-Token", csrfCookie.Value, "X-Token-Delivery", "cookie")
+
+	a.json(http.MethodPut, "/auth/password", map[string]string{"old_password": password, "new_password": password + "!"}, http.StatusOK, nil,
+		"Authorization", bearer(login.AccessToken))
+	a.json(http.MethodDelete, "/auth/user/refresh", map[string]string{"user_id": user.UserID}, http.StatusOK, nil, admin...)
+	credentials["password"] = password + "!"
+	a.json(http.MethodPost, "/auth/login", credentials, http.StatusOK, &login)
+
+	//OAuth 2.0 client using every grant.
+	var client struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	a.json(http.MethodPost, "/oauth/clients", map[string]interface{}{
+		"grant_types":   []string{entity.GrantAuthorizationCode, entity.GrantRefreshToken, entity.GrantClientCredentials, entity.GrantDeviceCode, entity.GrantTokenExchange},
+		"redirect_uris": []string{redirectURI},
+		"scope":         entity.ScopeOpenID,
+	}, http.StatusCreated, &client, admin...)
+	a.json(http.MethodPost, "/oauth/clients", map[string]interface{}{"grant_types": []string{entity.GrantClientCredentials}}, http.StatusForbidden, nil,
+		"Authorization", bearer(login.AccessToken))
+	clientAuth := []string{"Authorization", basic(client.ClientID, client.ClientSecret)}
+
+	verifier := strings.Repeat("v", 43)
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {entity.ScopeOpenID},
+		"state":                 {"state"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	w = a.do(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil, http.StatusFound, "Authorization", bearer(login.AccessToken))
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code oauthToken
+	a.form("/oauth/token", url.Values{
+		"grant_type":    {entity.GrantAuthorizationCode},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}, http.StatusOK, &code, clientAuth...)
+	if code.IDToken == "" {
+		t.Error("ID token is not issued for openid scope")
+	}
+	a.form("/oauth/token", url.Values{"grant_type": {entity.GrantAuthorizationCode}, "code": {"used"}}, http.StatusBadRequest, nil, clientAuth...)
+
+	//Browser can not send credentials in headers, so the user signs in at the login form.
+	w = a.do(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil, http.StatusOK)
+	form, csrfCookie := loginForm(t, w)
+	form.Set("user_id", user.UserID)
+	form.Set("password", "wrong password")
+	a.do(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()), http.StatusBadRequest,
+		"Content-Type", "application/x-www-form-urlencoded")
+	w = a.do(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()), http.StatusOK,
+		"Content-Type", "application/x-www-form-urlencoded", "Cookie", csrfCookie.Name+"="+csrfCookie.Value)
+	form, csrfCookie = loginForm(t, w)
+	form.Set("user_id", user.UserID)
+	form.Set("password", credentials["password"])
+	w = a.do(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()), http.StatusFound,
+		"Content-Type", "application/x-www-form-urlencoded", "Cookie", csrfCookie.Name+"="+csrfCookie.Value)
+	location, err = url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Query().Get("state") != "state" {
+		t.Errorf("got redirect %s, want state of the request", location)
+	}
+	a.form("/oauth/token", url.Values{
+		"grant_type":    {entity.GrantAuthorizationCode},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}, http.StatusOK, nil, clientAuth...)
+	a.form("/oauth/token", url.Values{"grant_type": {"password"}}, http.StatusBadRequest, nil, clientAuth...)
+	a.form("/oauth/token", url.Values{"grant_type": {entity.GrantClientCredentials}}, http.StatusUnauthorized, nil,
+		"Authorization", basic(client.ClientID, "wrong secret"))
+
+	a.do(http.MethodGet, "/userinfo", nil, http.StatusOK, "Authorization", bearer(code.AccessToken))
+	a.do(http.MethodPost, "/userinfo", nil, http.StatusOK, "Authorization", bearer(code.AccessToken))
+	a.do(http.MethodGet, "/userinfo", nil, http.StatusUnauthorized)
+
+	a.form("/oauth/token", url.Values{"grant_type": {entity.GrantRefreshToken}, "refresh_token": {code.RefreshToken}}, http.StatusOK, nil, clientAuth...)
+	a.form("/oauth/token", url.Values{"grant_type": {entity.GrantClientCredentials}}, http.StatusOK, nil, clientAuth...)
+	a.form("/oauth/token", url.Values{
+		"grant_type":         {entity.GrantTokenExchange},
+		"subject_token":      {code.AccessToken},
+		"subject_token_type": {entity.TokenTypeAccessToken},
+		"audience":           {"https://api.example"},
+	}, http.StatusOK, nil, clientAuth...)
+
+	var device struct {
+		DeviceCode string `json:"device_code"`
+		UserCode   string `json:"user_code"`
+	}
+	a.form("/oauth/device_authorization", url.Values{"client_id": {client.ClientID}}, http.StatusOK, &device, clientAuth...)
+	a.json(http.MethodGet, "/oauth/device?"+url.Values{"user_code": {device.UserCode}}.Encode(), nil, http.StatusOK, nil,
+		"Authorization", bearer(login.AccessToken))
+	a.json(http.MethodPost, "/oauth/device", map[string]interface{}{"user_code": device.UserCode, "approved": true}, http.StatusOK, nil,
+		"Authorization", bearer(login.AccessToken))
+	a.form("/oauth/token", url.Values{"grant_type": {entity.GrantDeviceCode}, "device_code": {device.DeviceCode}}, http.StatusOK, nil, clientAuth...)
+
+	a.json(http.MethodDelete, "/oauth/clients/"+client.ClientID+"/tokens", nil, http.StatusOK, nil, admin...)
+
+	//Administration.
+	a.json(http.MethodGet, "/admin/tokens?"+url.Values{"user_id": {user.UserID}, "status": {"active"}}.Encode(), nil, http.StatusOK, nil, admin...)
+	a.json(http.MethodGet, "/admin/tokens?status=unknown", nil, http.StatusBadRequest, nil, admin...)
+	a.json(http.MethodGet, "/admin/tokens", nil, http.StatusForbidden, nil, "Authorization", bearer(login.AccessToken))
+	a.json(http.MethodDelete, "/admin/tokens?"+url.Values{"user_id": {user.UserID}}.Encode(), nil, http.StatusOK, nil, admin...)
+	a.json(http.MethodPut, "/admin/not_before", map[string]string{"user_id": user.UserID}, http.StatusOK, nil, admin...)
+	a.json(http.MethodGet, "/admin/audit?type=issue&limit=2", nil, http.StatusOK, nil, admin...)
+	a.json(http.MethodGet, "/admin/audit?type=unknown", nil, http.StatusBadRequest, nil, admin...)
+	a.do(http.MethodGet, "/admin/audit/export?"+url.Values{"user_id": {user.UserID}}.Encode(), nil, http.StatusOK, admin...)
+
+	for path, item := range a.doc.Paths {
+		for method, op := range item {
+			if !a.exercised[op] {
+				t.Errorf("Documented route %s %s is not exercised", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
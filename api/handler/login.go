@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+//authorizeCSRFCookie holds CSRF token the login form of authorization endpoint echoes, so other sites can not sign
+//the user in with credentials of their choice and have code of their account sent to the client (login CSRF).
+const authorizeCSRFCookie = "authorize_csrf"
+
+//authorizePath is the only path authorizeCSRFCookie is sent to.
+const authorizePath = "/oauth/authorize"
+
+//loginFormTTL is how long the user may take to submit the login form.
+const loginFormTTL = 10 * time.Minute
+
+//authorizeParams are parameters of authorization request the login form carries from the query to it`s submission.
+var authorizeParams = []string{"response_type", "client_id", "redirect_uri", "scope", "state", "code_challenge", "code_challenge_method", "nonce"}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+</head>
+<body>
+<h1>Sign in to continue to {{.ClientID}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="` + authorizePath + `">
+{{range .Params}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<label>User id <input name="user_id" value="{{.UserID}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+type loginParam struct {
+	Name  string
+	Value string
+}
+
+type loginForm struct {
+	ClientID  string
+	Params    []loginParam
+	CSRFToken string
+	UserID    string
+	Error     string
+}
+
+//respondWithLoginForm renders login form submitting authorization request of params together with credentials of the user.
+//Every form gets a fresh CSRF token, which is also set as HttpOnly cookie of authorization endpoint.
+func respondWithLoginForm(params url.Values, userID, message string, w http.ResponseWriter, r *http.Request) {
+	csrf := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(csrf); err != nil {
+		respondWithError(err, w, r)
+		return
+	}
+	form := loginForm{
+		ClientID:  params.Get("client_id"),
+		CSRFToken: base64.RawURLEncoding.EncodeToString(csrf),
+		UserID:    userID,
+		Error:     message,
+	}
+	for _, name := range authorizeParams {
+		if value := params.Get(name); value != "" {
+			form.Params = append(form.Params, loginParam{name, value})
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     authorizeCSRFCookie,
+		Value:    form.CSRFToken,
+		Path:     authorizePath,
+		MaxAge:   int(loginFormTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	//The form must not be framed by other sites, which could trick the user into submitting it.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(http.StatusOK)
+	loginPage.Execute(w, form)
+}
+
+//checkLoginCSRF reports whether submitted login form echoes CSRF token cookie it was rendered with.
+func checkLoginCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(authorizeCSRFCookie)
+	token := r.PostForm.Get("csrf_token")
+	return err == nil && cookie.Value != "" && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) == 1
+}
+
+//clearLoginCSRF removes CSRF token cookie once the login form is done with.
+func clearLoginCSRF(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: authorizeCSRFCookie, Path: authorizePath, MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode})
+}
@@ -0,0 +1,508 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"example.com/auth-service-go/api/model"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"github.com/go-chi/chi"
+)
+
+//oauthError is an OAuth 2.0 error code with HTTP status it is reported with.
+type oauthError struct {
+	code   string
+	status int
+}
+
+//OAuth 2.0 error codes, see RFC 6749 section 5.2.
+var (
+	oauthInvalidRequest       = oauthError{"invalid_request", http.StatusBadRequest}
+	oauthInvalidClient        = oauthError{"invalid_client", http.StatusUnauthorized}
+	oauthInvalidGrant         = oauthError{"invalid_grant", http.StatusBadRequest}
+	oauthUnauthorizedClient   = oauthError{"unauthorized_client", http.StatusBadRequest}
+	oauthUnsupportedGrantType = oauthError{"unsupported_grant_type", http.StatusBadRequest}
+	oauthInvalidScope         = oauthError{"invalid_scope", http.StatusBadRequest}
+	//Device authorization grant errors, see RFC 8628 section 3.5.
+	oauthAuthorizationPending = oauthError{"authorization_pending", http.StatusBadRequest}
+	oauthSlowDown             = oauthError{"slow_down", http.StatusBadRequest}
+	oauthAccessDenied         = oauthError{"access_denied", http.StatusBadRequest}
+	oauthExpiredToken         = oauthError{"expired_token", http.StatusBadRequest}
+	//oauthUnsupportedResponseType is only reported by authorization endpoint, see RFC 6749 section 4.1.2.1.
+	oauthUnsupportedResponseType = oauthError{"unsupported_response_type", http.StatusBadRequest}
+	oauthServerError             = oauthError{"server_error", http.StatusInternalServerError}
+	oauthTemporarilyUnavailable  = oauthError{"temporarily_unavailable", http.StatusServiceUnavailable}
+	//oauthRequestCanceled is reported to the client which has gone before the request was done, it is only seen in logs.
+	oauthRequestCanceled = oauthError{"temporarily_unavailable", statusClientClosedRequest}
+)
+
+//oauthErrors maps domain errors onto OAuth 2.0 error codes.
+var oauthErrors = []struct {
+	err        error
+	oauthError oauthError
+}{
+	{entity.ErrInvalidArgument, oauthInvalidRequest},
+	{entity.ErrMalformedToken, oauthInvalidGrant},
+	{entity.ErrInvalidSignature, oauthInvalidGrant},
+	{entity.ErrInvalidToken, oauthInvalidGrant},
+	{entity.ErrTokenMismatch, oauthInvalidGrant},
+	{entity.ErrTokenExpired, oauthInvalidGrant},
+	{entity.ErrTokenNotFound, oauthInvalidGrant},
+	{entity.ErrTokenUsed, oauthInvalidGrant},
+	{entity.ErrCodeNotFound, oauthInvalidGrant},
+	{entity.ErrInvalidGrant, oauthInvalidGrant},
+	{entity.ErrInvalidScope, oauthInvalidScope},
+	{entity.ErrAuthorizationPending, oauthAuthorizationPending},
+	{entity.ErrSlowDown, oauthSlowDown},
+	{entity.ErrAccessDenied, oauthAccessDenied},
+	{entity.ErrSessionLimit, oauthAccessDenied},
+	{entity.ErrCodeExpired, oauthExpiredToken},
+	{entity.ErrUnauthenticated, oauthInvalidClient},
+	{entity.ErrClientNotFound, oauthInvalidClient},
+	{entity.ErrForbidden, oauthUnauthorizedClient},
+	{entity.ErrOverloaded, oauthTemporarilyUnavailable},
+	{entity.ErrStorageUnavailable, oauthTemporarilyUnavailable},
+	{context.Canceled, oauthRequestCanceled},
+	{context.DeadlineExceeded, oauthTemporarilyUnavailable},
+}
+
+//InitOAuthRoutes initializes /oauth subrouter. Token and device endpoints, where client secrets and user codes
+//could be guessed, are throttled by limiter unless it is nil.
+func (h *Handler) InitOAuthRoutes(auth *service.AuthService, limiter *service.RateLimiter) {
+	h.Router.Route("/oauth", func(r chi.Router) {
+		limited := r.With()
+		if limiter != nil {
+			limited = r.With(rateLimit(h.Router, limiter))
+		}
+		r.Get("/authorize", authorize(auth))
+		limited.Post("/authorize", authorizeLogin(auth))
+		limited.Post("/token", token(auth))
+		limited.Post("/device_authorization", deviceAuthorization(auth))
+		limited.Get("/device", deviceRequest(auth))
+		limited.Post("/device", verifyDevice(auth))
+		r.Post("/clients", registerClient(auth))
+		r.Delete("/clients/{clientID}/tokens", revokeClient(auth))
+	})
+}
+
+func authorize(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		client, redirectURI, err := auth.AuthorizationClient(r.Context(), query.Get("client_id"), query.Get("redirect_uri"))
+		if err != nil {
+			//User agent must not be redirected to unverified redirect URI.
+			respondWithError(err, w, r)
+			return
+		}
+
+		state := query.Get("state")
+		if query.Get("response_type") != "code" {
+			redirectWithOAuthError(oauthUnsupportedResponseType, "Response type must be code", redirectURI, state, w, r)
+			return
+		}
+
+		creds := credentials(r)
+		code, err := auth.Authorize(r.Context(), creds, client, authorizationRequest(query))
+		if errors.Is(err, entity.ErrUnauthenticated) {
+			if creds == (service.Credentials{}) {
+				//Browser brought here by the client can not send credentials in headers, so the user signs in at the login form.
+				respondWithLoginForm(query, "", "", w, r)
+				return
+			}
+			respondWithError(err, w, r)
+			return
+		}
+		if err != nil {
+			log.Println(err.Error())
+			oauthErr, description := oauthErrorFromError(err)
+			redirectWithOAuthError(oauthErr, description, redirectURI, state, w, r)
+			return
+		}
+
+		redirectWithParams(redirectURI, url.Values{"code": {code}, "state": {state}}, w, r)
+	}
+}
+
+//authorizeLogin handles submission of the login form of authorization endpoint, code is only issued if the password is right.
+func authorizeLogin(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != "application/x-www-form-urlencoded" || r.ParseForm() != nil {
+			respondWithProblem(problemInvalidRequest, "Login form must be application/x-www-form-urlencoded", w, r)
+			return
+		}
+		form := r.PostForm
+		client, redirectURI, err := auth.AuthorizationClient(r.Context(), form.Get("client_id"), form.Get("redirect_uri"))
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+		if !checkLoginCSRF(r) {
+			respondWithProblem(problemCSRF, "Login form must be submitted with CSRF token it was rendered with", w, r)
+			return
+		}
+
+		state := form.Get("state")
+		if form.Get("response_type") != "code" {
+			redirectWithOAuthError(oauthUnsupportedResponseType, "Response type must be code", redirectURI, state, w, r)
+			return
+		}
+
+		code, err := auth.AuthorizeWithPassword(r.Context(), form.Get("user_id"), form.Get("password"), client, authorizationRequest(form))
+		if errors.Is(err, entity.ErrUnauthenticated) {
+			respondWithLoginForm(form, form.Get("user_id"), "User id or password is wrong", w, r)
+			return
+		}
+		clearLoginCSRF(w)
+		if err != nil {
+			log.Println(err.Error())
+			oauthErr, description := oauthErrorFromError(err)
+			redirectWithOAuthError(oauthErr, description, redirectURI, state, w, r)
+			return
+		}
+
+		redirectWithParams(redirectURI, url.Values{"code": {code}, "state": {state}}, w, r)
+	}
+}
+
+//authorizationRequest returns authorization request of query or login form parameters.
+func authorizationRequest(params url.Values) service.AuthorizationRequest {
+	return service.AuthorizationRequest{
+		RedirectURI:         params.Get("redirect_uri"),
+		Scopes:              entity.ParseScope(params.Get("scope")),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Nonce:               params.Get("nonce"),
+	}
+}
+
+func token(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !parseOAuthForm(w, r) {
+			return
+		}
+
+		grantType := r.PostForm.Get("grant_type")
+		switch grantType {
+		case entity.GrantAuthorizationCode, entity.GrantRefreshToken, entity.GrantClientCredentials, entity.GrantDeviceCode, entity.GrantTokenExchange:
+		case "":
+			respondWithOAuthError(oauthInvalidRequest, "Grant type is empty", w)
+			return
+		default:
+			respondWithOAuthError(oauthUnsupportedGrantType, "Grant type is not supported", w)
+			return
+		}
+
+		creds, ok := clientCredentials(r)
+		if !ok {
+			respondWithOAuthError(oauthInvalidRequest, "Client must use only one authentication method", w)
+			return
+		}
+		//Client authentication is only optional for refresh tokens issued directly to users.
+		var client *entity.Client
+		var err error
+		if creds.Method != "" {
+			client, err = auth.AuthenticateClient(r.Context(), creds)
+		}
+
+		var tokenPair *service.TokenPair
+		if err == nil {
+			ctx := withDevice(r)
+			switch {
+			case grantType == entity.GrantRefreshToken:
+				tokenPair, err = auth.RefreshGrant(ctx, client, r.PostForm.Get("refresh_token"), entity.ParseScope(r.PostForm.Get("scope")))
+			case client == nil:
+				err = entity.ErrUnauthenticated
+			case grantType == entity.GrantAuthorizationCode:
+				tokenPair, err = auth.AuthorizationCodeGrant(ctx, client, r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+			case grantType == entity.GrantDeviceCode:
+				tokenPair, err = auth.DeviceCodeGrant(ctx, client, r.PostForm.Get("device_code"))
+			case grantType == entity.GrantTokenExchange:
+				tokenPair, err = auth.TokenExchangeGrant(ctx, client, service.TokenExchangeRequest{
+					SubjectToken:       r.PostForm.Get("subject_token"),
+					SubjectTokenType:   r.PostForm.Get("subject_token_type"),
+					ActorToken:         r.PostForm.Get("actor_token"),
+					ActorTokenType:     r.PostForm.Get("actor_token_type"),
+					Audience:           r.PostForm.Get("audience"),
+					Scopes:             entity.ParseScope(r.PostForm.Get("scope")),
+					RequestedTokenType: r.PostForm.Get("requested_token_type"),
+				})
+			default:
+				tokenPair, err = auth.ClientCredentialsGrant(ctx, client, entity.ParseScope(r.PostForm.Get("scope")))
+			}
+		}
+		if err != nil {
+			respondWithClientError(err, w)
+			return
+		}
+
+		var issuedTokenType string
+		if grantType == entity.GrantTokenExchange {
+			issuedTokenType = entity.TokenTypeAccessToken
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model.OAuthToken{
+			AccessToken:     tokenPair.AccessToken,
+			IssuedTokenType: issuedTokenType,
+			TokenType:       "Bearer",
+			ExpiresIn:       tokenPair.AccessTokenExpiresAt - auth.Now().Unix(),
+			RefreshToken:    tokenPair.RefreshToken,
+			IDToken:         tokenPair.IDToken,
+			Scope:           entity.FormatScope(tokenPair.Scopes),
+		})
+	}
+}
+
+func deviceAuthorization(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !parseOAuthForm(w, r) {
+			return
+		}
+		creds, ok := clientCredentials(r)
+		if !ok {
+			respondWithOAuthError(oauthInvalidRequest, "Client must use only one authentication method", w)
+			return
+		}
+		if creds.Method == "" {
+			respondWithClientError(entity.ErrUnauthenticated, w)
+			return
+		}
+
+		client, err := auth.AuthenticateClient(r.Context(), creds)
+		var authorization *service.DeviceAuthorization
+		if err == nil {
+			authorization, err = auth.AuthorizeDevice(r.Context(), client, entity.ParseScope(r.PostForm.Get("scope")))
+		}
+		if err != nil {
+			respondWithClientError(err, w)
+			return
+		}
+
+		verificationURI := strings.TrimSuffix(auth.Issuer(), "/") + deviceVerificationEndpoint
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model.DeviceAuthorization{
+			DeviceCode:              authorization.DeviceCode,
+			UserCode:                authorization.UserCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {authorization.UserCode}}.Encode(),
+			ExpiresIn:               authorization.ExpiresIn,
+			Interval:                authorization.Interval,
+		})
+	}
+}
+
+func deviceRequest(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, err := auth.DeviceRequest(r.Context(), credentials(r), r.URL.Query().Get("user_code"))
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		respondWithJSON("data", model.DeviceRequest{
+			ClientID:  request.ClientID,
+			Scope:     entity.FormatScope(request.Scopes),
+			ExpiresAt: request.ExpiresAt,
+		}, http.StatusOK, w)
+	}
+}
+
+func verifyDevice(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		verification := &model.DeviceVerification{}
+		err := json.NewDecoder(r.Body).Decode(verification)
+		if err != nil {
+			respondWithProblem(problemInvalidRequest, "Error parsing device verification", w, r)
+			return
+		}
+
+		err = auth.VerifyDevice(r.Context(), credentials(r), verification.UserCode, verification.Approved)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		message := "Device authorization request was successfully denied"
+		if verification.Approved {
+			message = "Device authorization request was successfully approved"
+		}
+		respondWithJSON("message", message, http.StatusOK, w)
+	}
+}
+
+//parseOAuthForm parses form encoded body of OAuth 2.0 endpoint request.
+//It reports false after responding with an error if the request is not form encoded.
+func parseOAuthForm(w http.ResponseWriter, r *http.Request) bool {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/x-www-form-urlencoded" {
+		respondWithOAuthError(oauthInvalidRequest, "Request must be application/x-www-form-urlencoded", w)
+		return false
+	}
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(oauthInvalidRequest, "Error parsing request", w)
+		return false
+	}
+	return true
+}
+
+//clientCredentials extracts OAuth 2.0 client credentials from Authorization header or request body.
+//Method of the result is empty if client did not authenticate.
+//It reports false if client used more than one authentication method, see RFC 6749 section 2.3.
+func clientCredentials(r *http.Request) (service.ClientCredentials, bool) {
+	var methods []service.ClientCredentials
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		//Client id and secret are form encoded before being put into the header, see RFC 6749 section 2.3.1.
+		id, idErr := url.QueryUnescape(clientID)
+		secret, secretErr := url.QueryUnescape(clientSecret)
+		if idErr != nil || secretErr != nil {
+			return service.ClientCredentials{}, false
+		}
+		methods = append(methods, service.ClientCredentials{Method: entity.ClientAuthSecretBasic, ClientID: id, ClientSecret: secret})
+	}
+	clientID := r.PostForm.Get("client_id")
+	if clientSecret := r.PostForm.Get("client_secret"); clientSecret != "" {
+		methods = append(methods, service.ClientCredentials{Method: entity.ClientAuthSecretPost, ClientID: clientID, ClientSecret: clientSecret})
+	}
+	if assertionType := r.PostForm.Get("client_assertion_type"); assertionType != "" {
+		if assertionType != entity.ClientAssertionType {
+			return service.ClientCredentials{}, false
+		}
+		methods = append(methods, service.ClientCredentials{Method: entity.ClientAuthPrivateKeyJWT, ClientID: clientID, Assertion: r.PostForm.Get("client_assertion")})
+	}
+
+	switch len(methods) {
+	case 0:
+		if clientID == "" {
+			return service.ClientCredentials{}, true
+		}
+		return service.ClientCredentials{Method: entity.ClientAuthNone, ClientID: clientID}, true
+	case 1:
+		creds := methods[0]
+		//Client id in the body must match the authenticated one.
+		if clientID != "" && clientID != creds.ClientID {
+			return creds, false
+		}
+		return creds, true
+	default:
+		return service.ClientCredentials{}, false
+	}
+}
+
+func registerClient(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		registration := &model.ClientRegistration{}
+		err := json.NewDecoder(r.Body).Decode(registration)
+		if err != nil {
+			respondWithProblem(problemInvalidRequest, "Error parsing client registration", w, r)
+			return
+		}
+
+		client, secret, err := auth.RegisterClient(r.Context(), credentials(r), entity.Client{
+			AuthMethod:      registration.TokenEndpointAuthMethod,
+			GrantTypes:      registration.GrantTypes,
+			RedirectURIs:    registration.RedirectURIs,
+			Scopes:          entity.ParseScope(registration.Scope),
+			PublicKey:       registration.PublicKey,
+			AccessTokenTTL:  registration.AccessTokenTTL,
+			RefreshTokenTTL: registration.RefreshTokenTTL,
+		})
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		respondWithJSON("data", model.Client{
+			ClientID:                client.ID,
+			ClientSecret:            secret,
+			ClientIDIssuedAt:        client.CreatedAt,
+			TokenEndpointAuthMethod: client.AuthMethod,
+			GrantTypes:              client.GrantTypes,
+			RedirectURIs:            client.RedirectURIs,
+			Scope:                   entity.FormatScope(client.Scopes),
+			AccessTokenTTL:          client.AccessTokenTTL,
+			RefreshTokenTTL:         client.RefreshTokenTTL,
+		}, http.StatusCreated, w)
+	}
+}
+
+func revokeClient(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := auth.RevokeClient(withDevice(r), credentials(r), chi.URLParam(r, "clientID"))
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		respondWithJSON("message", "Client refresh tokens was successfully deleted", http.StatusOK, w)
+	}
+}
+
+//oauthErrorFromError returns OAuth 2.0 error and client safe description for the given error.
+func oauthErrorFromError(err error) (oauthError, string) {
+	var argErr *entity.ArgumentError
+	if errors.As(err, &argErr) {
+		return oauthInvalidRequest, argErr.Message
+	}
+	for _, e := range oauthErrors {
+		if errors.Is(err, e.err) {
+			return e.oauthError, e.err.Error()
+		}
+	}
+	return oauthServerError, ""
+}
+
+//redirectWithOAuthError is a helper for redirecting user agent back to the client with OAuth 2.0 error, see RFC 6749 section 4.1.2.1.
+func redirectWithOAuthError(e oauthError, description, redirectURI, state string, w http.ResponseWriter, r *http.Request) {
+	redirectWithParams(redirectURI, url.Values{"error": {e.code}, "error_description": {description}, "state": {state}}, w, r)
+}
+
+//redirectWithParams redirects user agent to redirect URI with non empty params added to it`s query.
+func redirectWithParams(redirectURI string, params url.Values, w http.ResponseWriter, r *http.Request) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		respondWithError(err, w, r)
+		return
+	}
+	query := u.Query()
+	for key, values := range params {
+		if values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+//respondWithClientError is a helper for handling OAuth 2.0 error responses of endpoints clients authenticate to.
+func respondWithClientError(err error, w http.ResponseWriter) {
+	log.Println(err.Error())
+	oauthErr, description := oauthErrorFromError(err)
+	if oauthErr == oauthInvalidClient {
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+	}
+	respondWithOAuthError(oauthErr, description, w)
+}
+
+//respondWithOAuthError is a helper for handling OAuth 2.0 error responses.
+func respondWithOAuthError(e oauthError, description string, w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(model.OAuthError{
+		Error:            e.code,
+		ErrorDescription: description,
+	})
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"example.com/auth-service-go/api/model"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"github.com/go-chi/chi"
+)
+
+//OpenID Connect routes. Discovery document only advertises the ones actually registered.
+const (
+	authorizationEndpoint = "/oauth/authorize"
+	tokenEndpoint         = "/oauth/token"
+	//deviceAuthorizationEndpoint is advertised as described in RFC 8628 section 4.
+	deviceAuthorizationEndpoint = "/oauth/device_authorization"
+	//deviceVerificationEndpoint is a verification URI users approve device authorization requests at.
+	deviceVerificationEndpoint = "/oauth/device"
+	userinfoEndpoint           = "/userinfo"
+	jwksEndpoint               = "/.well-known/jwks.json"
+)
+
+//InitOIDCRoutes initializes OpenID Connect discovery, keys and userinfo routes.
+func (h *Handler) InitOIDCRoutes(auth *service.AuthService) {
+	h.Router.Get("/.well-known/openid-configuration", openIDConfiguration(h.Router, auth))
+	h.Router.Get(jwksEndpoint, jwks(auth))
+	h.Router.Get(userinfoEndpoint, userinfo(auth))
+	h.Router.Post(userinfoEndpoint, userinfo(auth))
+}
+
+func openIDConfiguration(routes chi.Routes, auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		registered := map[string]bool{}
+		chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			registered[method+" "+strings.TrimSuffix(route, "/")] = true
+			return nil
+		})
+		issuer := strings.TrimSuffix(auth.Issuer(), "/")
+		endpoint := func(method, path string) string {
+			if !registered[method+" "+path] {
+				return ""
+			}
+			return issuer + path
+		}
+
+		config := model.OpenIDConfiguration{
+			Issuer:                                     issuer,
+			AuthorizationEndpoint:                      endpoint(http.MethodGet, authorizationEndpoint),
+			TokenEndpoint:                              endpoint(http.MethodPost, tokenEndpoint),
+			UserinfoEndpoint:                           endpoint(http.MethodGet, userinfoEndpoint),
+			DeviceAuthorizationEndpoint:                endpoint(http.MethodPost, deviceAuthorizationEndpoint),
+			JWKSURI:                                    issuer + jwksEndpoint,
+			ScopesSupported:                            []string{entity.ScopeOpenID},
+			ResponseTypesSupported:                     []string{"code"},
+			GrantTypesSupported:                        []string{entity.GrantAuthorizationCode, entity.GrantRefreshToken, entity.GrantClientCredentials, entity.GrantDeviceCode, entity.GrantTokenExchange},
+			SubjectTypesSupported:                      []string{"public"},
+			IDTokenSigningAlgValuesSupported:           []string{},
+			TokenEndpointAuthMethodsSupported:          []string{entity.ClientAuthSecretBasic, entity.ClientAuthSecretPost, entity.ClientAuthPrivateKeyJWT, entity.ClientAuthNone},
+			TokenEndpointAuthSigningAlgValuesSupported: []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"},
+			CodeChallengeMethodsSupported:              []string{entity.CodeChallengeMethodS256},
+			ClaimsSupported:                            []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "updated_at"},
+		}
+		if key := auth.IDTokenKey(); key != nil {
+			config.IDTokenSigningAlgValuesSupported = append(config.IDTokenSigningAlgValuesSupported, key.Algorithm())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(config)
+	}
+}
+
+func jwks(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		set := model.JWKS{Keys: []model.JWK{}}
+		if key := auth.IDTokenKey(); key != nil {
+			set.Keys = append(set.Keys, model.JWK{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: key.Algorithm(),
+				Kid: key.ID,
+				N:   key.PublicModulus(),
+				E:   key.PublicExponent(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}
+}
+
+func userinfo(auth *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := auth.UserInfo(r.Context(), credentials(r).BearerToken)
+		if err != nil {
+			respondWithError(err, w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model.UserInfo{
+			Sub:       info.UserID.String(),
+			UpdatedAt: info.UpdatedAt,
+		})
+	}
+}
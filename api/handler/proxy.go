@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+//clientIP resolves IP address of the client once per request for remoteIP.
+//Each of TrustedProxies appends address of it`s client to X-Forwarded-For, so the address of the client is
+//TrustedProxies entries from the end. Entries in front of it are sent by the client and may be forged.
+func (h *Handler) clientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := connectionIP(r)
+		if forwarded := forwardedFor(r); h.TrustedProxies > 0 && len(forwarded) != 0 {
+			if len(forwarded) < h.TrustedProxies {
+				ip = forwarded[0]
+			} else {
+				ip = forwarded[len(forwarded)-h.TrustedProxies]
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+//forwardedFor returns addresses of all X-Forwarded-For headers in order they were appended.
+func forwardedFor(r *http.Request) []string {
+	var addrs []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(header, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxies   int
+		forwarded []string
+		ip        string
+	}{
+		{"no proxies", 0, nil, "10.0.0.1"},
+		{"spoofed header without proxies", 0, []string{"203.0.113.7"}, "10.0.0.1"},
+		{"spoofed list without proxies", 0, []string{"203.0.113.7, 198.51.100.2"}, "10.0.0.1"},
+		{"proxy without header", 1, nil, "10.0.0.1"},
+		{"single proxy", 1, []string{"198.51.100.2"}, "198.51.100.2"},
+		{"spoofed entry in front of proxy", 1, []string{"203.0.113.7, 198.51.100.2"}, "198.51.100.2"},
+		{"spoofed header in front of proxy", 1, []string{"203.0.113.7", "198.51.100.2"}, "198.51.100.2"},
+		{"two proxies", 2, []string{"203.0.113.7, 198.51.100.2, 192.0.2.3"}, "198.51.100.2"},
+		{"two proxies in separate headers", 2, []string{"198.51.100.2", "192.0.2.3"}, "198.51.100.2"},
+		{"fewer entries than proxies", 3, []string{"198.51.100.2, 192.0.2.3"}, "198.51.100.2"},
+		{"empty entries", 1, []string{" , 198.51.100.2 ,"}, "198.51.100.2"},
+		{"only empty entries", 1, []string{" , "}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TrustedProxies: tt.proxies}
+			var ip string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { ip = remoteIP(r) })
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:4242"
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			h.clientIP(next).ServeHTTP(httptest.NewRecorder(), r)
+			if ip != tt.ip {
+				t.Errorf("got client IP %s, want %s", ip, tt.ip)
+			}
+		})
+	}
+}
+
+func TestConnectionIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		ip         string
+	}{
+		{"10.0.0.1:4242", "10.0.0.1"},
+		{"[2001:db8::1]:4242", "2001:db8::1"},
+		{"10.0.0.1", "10.0.0.1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if ip := connectionIP(r); ip != tt.ip {
+			t.Errorf("got %q of %q, want %q", ip, tt.remoteAddr, tt.ip)
+		}
+	}
+}
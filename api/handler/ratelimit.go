@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"github.com/go-chi/chi"
+)
+
+//maxPeekedBody is the largest request body inspected for user id by rate limiting.
+const maxPeekedBody = 1 << 16
+
+//rateLimit throttles requests by IP address of the client and by id of the user the request is about.
+//Limits are looked up by method and route pattern, e.g. "POST /auth/login".
+func rateLimit(router *chi.Mux, limiter *service.RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rctx := chi.NewRouteContext()
+			if !router.Match(rctx, r.Method, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			keys := []string{"ip:" + remoteIP(r)}
+			if userID := rateLimitedUser(r, rctx); userID != "" {
+				keys = append(keys, "user:"+userID)
+			}
+			if wait := limiter.Allow(r.Context(), r.Method+" "+rctx.RoutePattern(), keys...); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				respondWithProblem(problemRateLimited, "Rate limit is exceeded", w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//rateLimitedUser returns canonical id of the user the request is about, so guessing passwords
+//of one user from many addresses is limited too. Id is taken from the path, basic credentials or user_id of JSON or form body.
+func rateLimitedUser(r *http.Request, rctx *chi.Context) string {
+	raw := rctx.URLParam("userID")
+	if raw == "" {
+		raw, _, _ = r.BasicAuth()
+	}
+	if raw == "" && r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPeekedBody))
+		//Handlers read the whole body, so peeked part is put back in front of the rest.
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil {
+			return ""
+		}
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType == "application/x-www-form-urlencoded" {
+			if form, err := url.ParseQuery(string(body)); err == nil {
+				raw = form.Get("user_id")
+			}
+		} else {
+			fields := struct {
+				UserID string `json:"user_id"`
+			}{}
+			if json.Unmarshal(body, &fields) == nil {
+				raw = fields.UserID
+			}
+		}
+	}
+	userID, err := entity.ParseUserID(raw)
+	if err != nil {
+		return ""
+	}
+	return string(userID)
+}
+
+//readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,30 @@
+package model
+
+//ClientRegistration is a type for api JSON representation of OAuth 2.0 client registration request.
+//Field names follow client metadata of RFC 7591 section 2.
+type ClientRegistration struct {
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types"`
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	//Scope is a space separated list of scopes client may request.
+	Scope string `json:"scope,omitempty"`
+	//PublicKey is a PEM encoded public key of private_key_jwt client.
+	PublicKey string `json:"public_key,omitempty"`
+	//AccessTokenTTL and RefreshTokenTTL override lifetimes of tokens issued to the client, in seconds.
+	AccessTokenTTL  int64 `json:"access_token_ttl,omitempty"`
+	RefreshTokenTTL int64 `json:"refresh_token_ttl,omitempty"`
+}
+
+//Client is a type for api JSON representation of registered OAuth 2.0 client as described in RFC 7591 section 3.2.1.
+type Client struct {
+	ClientID string `json:"client_id"`
+	//ClientSecret is only returned once on registration.
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64    `json:"client_id_issued_at"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	GrantTypes              []string `json:"grant_types"`
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	AccessTokenTTL          int64    `json:"access_token_ttl,omitempty"`
+	RefreshTokenTTL         int64    `json:"refresh_token_ttl,omitempty"`
+}
@@ -0,0 +1,45 @@
+package model
+
+//OAuthToken is a type for api JSON representation of successful OAuth 2.0 token response as described in RFC 6749 section 5.1.
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	//IssuedTokenType is only returned by token exchange, see RFC 8693 section 2.2.1.
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	//Scope is a space separated list of granted scopes.
+	Scope string `json:"scope,omitempty"`
+	//IDToken is an OpenID Connect ID token, it is only returned if openid scope was granted.
+	IDToken string `json:"id_token,omitempty"`
+}
+
+//OAuthError is a type for api JSON representation of OAuth 2.0 error response as described in RFC 6749 section 5.2.
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+//DeviceAuthorization is a type for api JSON representation of OAuth 2.0 device authorization response as described in RFC 8628 section 3.2.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+//DeviceRequest is a type for api JSON representation of pending device authorization request shown to the user.
+type DeviceRequest struct {
+	ClientID string `json:"client_id"`
+	//Scope is a space separated list of requested scopes.
+	Scope     string `json:"scope,omitempty"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+//DeviceVerification is a type for api JSON representation of user decision on device authorization request.
+type DeviceVerification struct {
+	UserCode string `json:"user_code"`
+	Approved bool   `json:"approved"`
+}
@@ -0,0 +1,42 @@
+package model
+
+//OpenIDConfiguration is a type for api JSON representation of OpenID Connect discovery document.
+//Field names follow OpenID Connect Discovery section 3, endpoints are omitted if they are not served.
+type OpenIDConfiguration struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                              string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint,omitempty"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+}
+
+//JWKS is a type for api JSON representation of JSON Web Key Set as described in RFC 7517 section 5.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+//JWK is a type for api JSON representation of RSA public JSON Web Key as described in RFC 7517 section 4.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+//UserInfo is a type for api JSON representation of OpenID Connect userinfo response.
+type UserInfo struct {
+	Sub       string `json:"sub" format:"uuid"`
+	UpdatedAt int64  `json:"updated_at,omitempty"`
+}
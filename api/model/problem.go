@@ -0,0 +1,12 @@
+package model
+
+//Problem is a type for api JSON representation of error response as described in RFC 7807.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	//Code is a stable machine readable error code clients can rely on.
+	Code string `json:"code"`
+}
@@ -2,11 +2,103 @@ package model
 
 //TokenPair is a type for api JSON representations of request with provided pair of access/refresh tokens.
 type TokenPair struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken string `json:"access_token"`
+	//RefreshToken is left out of responses and requests of browser clients holding it in cookie.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	//Scope is a space separated list of scopes. In requests it narrows scopes of the refresh token.
+	Scope string `json:"scope,omitempty"`
 }
 
 //RefreshToken is a type for api JSON representation of request with provided refresh token.
 type RefreshToken struct {
-	Token string `json:"refresh_token"`
+	//Token is left out by browser clients holding refresh token in cookie.
+	Token string `json:"refresh_token,omitempty"`
+}
+
+//Session is a type for api JSON representation of active session of the user.
+type Session struct {
+	ID       string `json:"id"`
+	ClientID string `json:"client_id,omitempty"`
+	//UserAgent and IP are the ones of the device the session was last rotated from.
+	UserAgent string `json:"user_agent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	RotatedAt int64  `json:"rotated_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	//Current is true for the session of the access token the request was authenticated with.
+	Current bool `json:"current"`
+}
+
+//AdminToken is a type for api JSON representation of stored refresh token for administrators.
+type AdminToken struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id" format:"uuid"`
+	ClientID  string `json:"client_id,omitempty"`
+	SessionID string `json:"session_id"`
+	//Scope is a space separated list of scopes granted to the token.
+	Scope string `json:"scope,omitempty"`
+	//Status is active, used or expired.
+	Status string `json:"status"`
+	//IssuedAt is absent for tokens issued before issuance time was recorded.
+	IssuedAt  int64  `json:"issued_at,omitempty"`
+	ExpiresAt int64  `json:"expires_at"`
+	UserAgent string `json:"user_agent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+}
+
+//TokenList is a type for api JSON representation of page of refresh tokens.
+type TokenList struct {
+	Tokens []AdminToken `json:"tokens"`
+	//NextOffset is an offset of the next page, it is absent on the last page.
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+//RevokedTokens is a type for api JSON representation of result of bulk revocation.
+type RevokedTokens struct {
+	Deleted int64 `json:"deleted"`
+}
+
+//NotBefore is a type for api JSON representation of not before watermark, tokens issued before it are revoked.
+type NotBefore struct {
+	//UserID limits watermark to tokens of the user, watermark without it applies to tokens of everyone.
+	UserID string `json:"user_id,omitempty" format:"uuid"`
+	//NotBefore is unix time in seconds, it defaults to the current time.
+	NotBefore int64 `json:"not_before,omitempty"`
+}
+
+//AuditEvent is a type for api JSON representation of audit event of refresh token lifecycle.
+type AuditEvent struct {
+	ID string `json:"id"`
+	//Type is issue, rotate, reuse_detected, revoke or bulk_revoke.
+	Type string `json:"type"`
+	Time int64  `json:"time"`
+	//Actor is the authenticated caller.
+	Actor string `json:"actor,omitempty" format:"uuid"`
+	//UserID is the user whose tokens are affected.
+	UserID    string   `json:"user_id,omitempty" format:"uuid"`
+	ClientID  string   `json:"client_id,omitempty"`
+	SessionID string   `json:"session_id,omitempty"`
+	TokenIDs  []string `json:"token_ids,omitempty"`
+	//Count is a number of refresh tokens deleted by revoke and bulk_revoke events.
+	Count int64 `json:"count,omitempty"`
+	//Filter is the admin filter of bulk_revoke event.
+	Filter    *AuditTokenFilter `json:"filter,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	//Result is success or the error the operation failed with.
+	Result string `json:"result"`
+}
+
+//AuditTokenFilter is a type for api JSON representation of filter of bulk_revoke audit event.
+type AuditTokenFilter struct {
+	IssuedAfter  int64  `json:"issued_after,omitempty"`
+	IssuedBefore int64  `json:"issued_before,omitempty"`
+	Status       string `json:"status,omitempty"`
+}
+
+//AuditEventList is a type for api JSON representation of page of audit events.
+type AuditEventList struct {
+	Events []AuditEvent `json:"events"`
+	//NextOffset is an offset of the next page, it is absent on the last page.
+	NextOffset int `json:"next_offset,omitempty"`
 }
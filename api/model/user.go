@@ -2,5 +2,24 @@ package model
 
 //User is a type for api JSON representation of request with provided user id.
 type User struct {
-	UserID string `json:"user_id"`
+	UserID string `json:"user_id" format:"uuid"`
+}
+
+//Credentials is a type for api JSON representation of login request.
+type Credentials struct {
+	UserID   string `json:"user_id" format:"uuid"`
+	Password string `json:"password"`
+	//Scope is a space separated list of requested scopes, all allowed scopes are granted if it is empty.
+	Scope string `json:"scope,omitempty"`
+}
+
+//Registration is a type for api JSON representation of registration request.
+type Registration struct {
+	Password string `json:"password"`
+}
+
+//PasswordChange is a type for api JSON representation of password change request.
+type PasswordChange struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
 }
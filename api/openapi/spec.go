@@ -0,0 +1,590 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"example.com/auth-service-go/api/model"
+)
+
+//Version is a version of the API described by the document.
+const Version = "1.0.0"
+
+//New returns OpenAPI document describing every route of the service.
+func New() *Document {
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Auth service",
+			Description: "Issues, refreshes and revokes pairs of access/refresh tokens.",
+			Version:     Version,
+		},
+		Paths: map[string]PathItem{
+			"/": {
+				"get": {
+					OperationID: "index",
+					Summary:     "Placeholder for main app page",
+					Responses: map[string]Response{
+						"200": {Description: "App is running", Content: map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}},
+					},
+				},
+			},
+			"/openapi.json": {
+				"get": {
+					OperationID: "getOpenAPI",
+					Summary:     "This document",
+					Responses: map[string]Response{
+						"200": {Description: "OpenAPI document", Content: jsonContent(&Schema{Type: "object"})},
+					},
+				},
+			},
+			"/auth/user/{userID}": {
+				"get": {
+					OperationID: "issueTokens",
+					Summary:     "Issue pair of access/refresh tokens for user",
+					Parameters: []Parameter{
+						{Name: "userID", In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}},
+						{Name: "scope", In: "query", Description: "Space separated list of requested scopes", Schema: &Schema{Type: "string"}},
+						tokenDeliveryParameter,
+					},
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Pair of tokens", Content: jsonContent(ref("TokenPairResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/tokens/refresh": {
+				"post": {
+					OperationID: "refreshTokens",
+					Summary:     "Exchange pair of tokens for a new pair",
+					Description: "Refresh token is taken from refresh_token cookie if it is left out of the body and cookie delivery is requested.",
+					Parameters:  []Parameter{tokenDeliveryParameter, csrfTokenParameter},
+					RequestBody: jsonBody(ref("TokenPair")),
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "New pair of tokens", Content: jsonContent(ref("TokenPairResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/sessions": {
+				"get": {
+					OperationID: "listSessions",
+					Summary:     "List active sessions of the caller, the most recent first",
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Active sessions", Content: jsonContent(ref("SessionsResponse"))},
+					}, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/sessions/{sessionID}": {
+				"delete": {
+					OperationID: "revokeSession",
+					Summary:     "Delete refresh tokens of particular session of the caller",
+					Parameters: []Parameter{
+						{Name: "sessionID", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+					},
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Session was deleted", Content: jsonContent(ref("MessageResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/refresh": {
+				"delete": {
+					OperationID: "revokeRefreshToken",
+					Summary:     "Delete particular refresh token",
+					Description: "Refresh token is taken from refresh_token cookie if it is left out of the body and cookie delivery is requested, " +
+						"the cookie is removed then.",
+					Parameters:  []Parameter{tokenDeliveryParameter, csrfTokenParameter},
+					RequestBody: jsonBody(ref("RefreshToken")),
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Refresh token was deleted", Content: jsonContent(ref("MessageResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/user/refresh": {
+				"delete": {
+					OperationID: "revokeUserRefreshTokens",
+					Summary:     "Delete all refresh tokens of user",
+					RequestBody: jsonBody(ref("User")),
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Refresh tokens were deleted", Content: jsonContent(ref("MessageResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/register": {
+				"post": {
+					OperationID: "register",
+					Summary:     "Register a new user with password",
+					RequestBody: jsonBody(ref("Registration")),
+					Responses: withProblems(map[string]Response{
+						"201": {Description: "User was registered", Content: jsonContent(ref("UserResponse"))},
+					}, http.StatusBadRequest, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/login": {
+				"post": {
+					OperationID: "login",
+					Summary:     "Verify password of user and issue pair of tokens",
+					Parameters:  []Parameter{tokenDeliveryParameter},
+					RequestBody: jsonBody(ref("Credentials")),
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Pair of tokens", Content: jsonContent(ref("TokenPairResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/auth/password": {
+				"put": {
+					OperationID: "changePassword",
+					Summary:     "Change password of the caller and revoke all it`s tokens",
+					RequestBody: jsonBody(ref("PasswordChange")),
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Password was changed", Content: jsonContent(ref("MessageResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/.well-known/openid-configuration": {
+				"get": {
+					OperationID: "openIDConfiguration",
+					Summary:     "OpenID Connect discovery document",
+					Responses: map[string]Response{
+						"200": {Description: "Discovery document", Content: jsonContent(SchemaOf(model.OpenIDConfiguration{}))},
+					},
+				},
+			},
+			"/.well-known/jwks.json": {
+				"get": {
+					OperationID: "jwks",
+					Summary:     "Public keys ID tokens are signed with",
+					Responses: map[string]Response{
+						"200": {Description: "JSON Web Key Set", Content: jsonContent(SchemaOf(model.JWKS{}))},
+					},
+				},
+			},
+			"/userinfo": {
+				"get": {
+					OperationID: "getUserInfo",
+					Summary:     "OpenID Connect claims about the user access token was issued for",
+					Security:    []map[string][]string{{"bearer": {}}},
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Claims about the user", Content: jsonContent(SchemaOf(model.UserInfo{}))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable),
+				},
+				"post": {
+					OperationID: "postUserInfo",
+					Summary:     "OpenID Connect claims about the user access token was issued for",
+					Security:    []map[string][]string{{"bearer": {}}},
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Claims about the user", Content: jsonContent(SchemaOf(model.UserInfo{}))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable),
+				},
+			},
+			"/oauth/authorize": {
+				"get": {
+					OperationID: "oauthAuthorize",
+					Summary:     "OAuth 2.0 authorization endpoint issuing authorization codes with mandatory PKCE",
+					Description: "User authenticates the same way as for other routes, browser sending no credentials gets the login form instead. " +
+						"Result or OAuth 2.0 error is sent to redirect URI, problems with client or redirect URI are reported directly.",
+					Parameters: []Parameter{
+						{Name: "response_type", In: "query", Required: true, Schema: &Schema{Type: "string", Enum: []string{"code"}}},
+						{Name: "client_id", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+						{Name: "redirect_uri", In: "query", Schema: &Schema{Type: "string"}},
+						{Name: "scope", In: "query", Schema: &Schema{Type: "string"}},
+						{Name: "state", In: "query", Schema: &Schema{Type: "string"}},
+						{Name: "code_challenge", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+						{Name: "code_challenge_method", In: "query", Required: true, Schema: &Schema{Type: "string", Enum: []string{"S256"}}},
+						{Name: "nonce", In: "query", Schema: &Schema{Type: "string"}},
+					},
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": loginFormResponse,
+						"302": {
+							Description: "Redirect to redirect URI with code and state or with OAuth 2.0 error",
+							Headers:     map[string]Header{"Location": {Schema: &Schema{Type: "string"}}},
+						},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusServiceUnavailable),
+				},
+				"post": {
+					OperationID: "oauthAuthorizeLogin",
+					Summary:     "Submission of the login form of authorization endpoint",
+					Description: "Form carries parameters of authorization request, credentials of the user and CSRF token set as cookie together with the form. " +
+						"Code is issued only if the password is right, otherwise the form is rendered again.",
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{"application/x-www-form-urlencoded": {Schema: ref("AuthorizeLoginRequest")}},
+					},
+					Security: []map[string][]string{{}},
+					Responses: withProblems(map[string]Response{
+						"200": loginFormResponse,
+						"302": {
+							Description: "Redirect to redirect URI with code and state or with OAuth 2.0 error",
+							Headers:     map[string]Header{"Location": {Schema: &Schema{Type: "string"}}},
+						},
+						"429": problemResponse(http.StatusTooManyRequests),
+					}, http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable),
+				},
+			},
+			"/oauth/token": {
+				"post": {
+					OperationID: "oauthToken",
+					Summary:     "OAuth 2.0 token endpoint supporting authorization_code, refresh_token, client_credentials, device_code and token-exchange grants",
+					Description: "Clients authenticate with client_secret_basic, client_secret_post or private_key_jwt, public clients only send client_id.",
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{"application/x-www-form-urlencoded": {Schema: ref("OAuthTokenRequest")}},
+					},
+					Security: []map[string][]string{{}, {"basic": {}}},
+					Responses: map[string]Response{
+						"200": {Description: "Issued tokens", Content: jsonContent(SchemaOf(model.OAuthToken{}))},
+						"400": {Description: "OAuth 2.0 error", Content: jsonContent(ref("OAuthError"))},
+						"401": {
+							Description: "Client authentication failed",
+							Headers:     map[string]Header{"WWW-Authenticate": {Schema: &Schema{Type: "string"}}},
+							Content:     jsonContent(ref("OAuthError")),
+						},
+						"429": problemResponse(http.StatusTooManyRequests),
+						"500": {Description: "OAuth 2.0 error", Content: jsonContent(ref("OAuthError"))},
+						"503": {Description: "OAuth 2.0 error", Content: jsonContent(ref("OAuthError"))},
+					},
+				},
+			},
+			"/oauth/device_authorization": {
+				"post": {
+					OperationID: "oauthDeviceAuthorization",
+					Summary:     "OAuth 2.0 device authorization endpoint issuing device and user codes",
+					Description: "Client polls token endpoint with device code while the user approves the request at verification URI.",
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{"application/x-www-form-urlencoded": {Schema: ref("DeviceAuthorizationRequest")}},
+					},
+					Security: []map[string][]string{{}, {"basic": {}}},
+					Responses: map[string]Response{
+						"200": {Description: "Device authorization request was created", Content: jsonContent(SchemaOf(model.DeviceAuthorization{}))},
+						"400": {Description: "OAuth 2.0 error", Content: jsonContent(ref("OAuthError"))},
+						"401": {
+							Description: "Client authentication failed",
+							Headers:     map[string]Header{"WWW-Authenticate": {Schema: &Schema{Type: "string"}}},
+							Content:     jsonContent(ref("OAuthError")),
+						},
+						"429": problemResponse(http.StatusTooManyRequests),
+						"500": {Description: "OAuth 2.0 error", Content: jsonContent(ref("OAuthError"))},
+						"503": {Description: "OAuth 2.0 error", Content: jsonContent(ref("OAuthError"))},
+					},
+				},
+			},
+			"/oauth/device": {
+				"get": {
+					OperationID: "getDeviceRequest",
+					Summary:     "Get pending device authorization request by user code",
+					Parameters: []Parameter{
+						{Name: "user_code", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+					},
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Pending device authorization request", Content: jsonContent(ref("DeviceRequestResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+				"post": {
+					OperationID: "verifyDevice",
+					Summary:     "Approve or deny device authorization request on behalf of the caller",
+					RequestBody: jsonBody(ref("DeviceVerification")),
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Decision was recorded", Content: jsonContent(ref("MessageResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/oauth/clients": {
+				"post": {
+					OperationID: "registerClient",
+					Summary:     "Register OAuth 2.0 client, client secret is only returned once",
+					RequestBody: jsonBody(ref("ClientRegistration")),
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"201": {Description: "Client was registered", Content: jsonContent(ref("ClientResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusServiceUnavailable),
+				},
+			},
+			"/oauth/clients/{clientID}/tokens": {
+				"delete": {
+					OperationID: "revokeClientRefreshTokens",
+					Summary:     "Delete all refresh tokens issued to OAuth 2.0 client",
+					Parameters: []Parameter{
+						{Name: "clientID", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+					},
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Refresh tokens were deleted", Content: jsonContent(ref("MessageResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusServiceUnavailable),
+				},
+			},
+			"/admin/tokens": {
+				"get": {
+					OperationID: "findTokens",
+					Summary:     "List refresh tokens matching the filter, the most recently issued first",
+					Parameters: append(tokenFilterParameters,
+						Parameter{Name: "offset", In: "query", Description: "Number of tokens to skip", Schema: &Schema{Type: "integer"}},
+						Parameter{Name: "limit", In: "query", Description: "Page size, 50 by default and at most 500", Schema: &Schema{Type: "integer"}},
+					),
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Page of refresh tokens", Content: jsonContent(ref("TokenListResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+				"delete": {
+					OperationID: "revokeTokens",
+					Summary:     "Delete all refresh tokens matching the filter in one transaction",
+					Description: "At least one filter parameter is required.",
+					Parameters:  tokenFilterParameters,
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Number of deleted refresh tokens", Content: jsonContent(ref("RevokedTokensResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/admin/audit": {
+				"get": {
+					OperationID: "findAuditEvents",
+					Summary:     "List audit events of refresh token lifecycle matching the filter in order of time",
+					Parameters: append(auditFilterParameters,
+						Parameter{Name: "offset", In: "query", Description: "Number of events to skip", Schema: &Schema{Type: "integer"}},
+						Parameter{Name: "limit", In: "query", Description: "Page size, 100 by default and at most 1000", Schema: &Schema{Type: "integer"}},
+					),
+					Security: callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Page of audit events", Content: jsonContent(ref("AuditEventListResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/admin/audit/export": {
+				"get": {
+					OperationID: "exportAuditEvents",
+					Summary:     "Export all audit events matching the filter in order of time",
+					Parameters:  auditFilterParameters,
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Audit events, one JSON object per line", Content: map[string]MediaType{"application/x-ndjson": {Schema: ref("AuditEvent")}}},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+			"/admin/not_before": {
+				"put": {
+					OperationID: "setNotBefore",
+					Summary:     "Revoke all tokens of the user or of everyone issued before given time",
+					Description: "Access and refresh tokens issued before the watermark or in the same second are rejected by all instances of the service. " +
+						"Watermark without user_id applies to everyone, not_before defaults to the current time. " +
+						"Watermark only moves forward, the effective watermark is returned.",
+					RequestBody: jsonBody(ref("NotBefore")),
+					Security:    callerSecurity,
+					Responses: withProblems(map[string]Response{
+						"200": {Description: "Watermark is set", Content: jsonContent(ref("NotBeforeResponse"))},
+					}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+				},
+			},
+		},
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"TokenPair":          SchemaOf(model.TokenPair{}),
+				"RefreshToken":       SchemaOf(model.RefreshToken{}),
+				"User":               SchemaOf(model.User{}),
+				"Problem":            SchemaOf(model.Problem{}),
+				"Credentials":        SchemaOf(model.Credentials{}),
+				"Registration":       SchemaOf(model.Registration{}),
+				"PasswordChange":     SchemaOf(model.PasswordChange{}),
+				"OAuthError":         SchemaOf(model.OAuthError{}),
+				"ClientRegistration": SchemaOf(model.ClientRegistration{}),
+				"AuthorizeLoginRequest": {
+					Type:     "object",
+					Required: []string{"response_type", "client_id", "code_challenge", "code_challenge_method", "csrf_token", "user_id", "password"},
+					Properties: map[string]*Schema{
+						"response_type":         {Type: "string", Enum: []string{"code"}},
+						"client_id":             {Type: "string"},
+						"redirect_uri":          {Type: "string"},
+						"scope":                 {Type: "string"},
+						"state":                 {Type: "string"},
+						"code_challenge":        {Type: "string"},
+						"code_challenge_method": {Type: "string", Enum: []string{"S256"}},
+						"nonce":                 {Type: "string"},
+						"csrf_token":            {Type: "string"},
+						"user_id":               {Type: "string", Format: "uuid"},
+						"password":              {Type: "string", Format: "password"},
+					},
+				},
+				"OAuthTokenRequest": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"grant_type":            {Type: "string", Enum: []string{"authorization_code", "refresh_token", "client_credentials", "urn:ietf:params:oauth:grant-type:device_code", "urn:ietf:params:oauth:grant-type:token-exchange"}},
+						"code":                  {Type: "string"},
+						"redirect_uri":          {Type: "string"},
+						"code_verifier":         {Type: "string"},
+						"refresh_token":         {Type: "string"},
+						"device_code":           {Type: "string"},
+						"subject_token":         {Type: "string"},
+						"subject_token_type":    {Type: "string", Enum: []string{"urn:ietf:params:oauth:token-type:access_token"}},
+						"actor_token":           {Type: "string"},
+						"actor_token_type":      {Type: "string", Enum: []string{"urn:ietf:params:oauth:token-type:access_token"}},
+						"audience":              {Type: "string", Description: "Logical name of the service exchanged token is intended for"},
+						"requested_token_type":  {Type: "string", Enum: []string{"urn:ietf:params:oauth:token-type:access_token"}},
+						"scope":                 {Type: "string", Description: "Space separated list of requested scopes"},
+						"client_id":             {Type: "string"},
+						"client_secret":         {Type: "string"},
+						"client_assertion_type": {Type: "string", Enum: []string{"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"}},
+						"client_assertion":      {Type: "string"},
+					},
+					Required: []string{"grant_type"},
+				},
+				"DeviceAuthorizationRequest": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"scope":                 {Type: "string", Description: "Space separated list of requested scopes"},
+						"client_id":             {Type: "string"},
+						"client_secret":         {Type: "string"},
+						"client_assertion_type": {Type: "string", Enum: []string{"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"}},
+						"client_assertion":      {Type: "string"},
+					},
+				},
+				"DeviceVerification": SchemaOf(model.DeviceVerification{}),
+				"DeviceRequestResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": SchemaOf(model.DeviceRequest{})},
+					Required:   []string{"data"},
+				},
+				"TokenListResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": SchemaOf(model.TokenList{})},
+					Required:   []string{"data"},
+				},
+				"RevokedTokensResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": SchemaOf(model.RevokedTokens{})},
+					Required:   []string{"data"},
+				},
+				"AuditEvent": SchemaOf(model.AuditEvent{}),
+				"AuditEventListResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": SchemaOf(model.AuditEventList{})},
+					Required:   []string{"data"},
+				},
+				"NotBefore": SchemaOf(model.NotBefore{}),
+				"NotBeforeResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": SchemaOf(model.NotBefore{})},
+					Required:   []string{"data"},
+				},
+				"SessionsResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": {Type: "array", Items: SchemaOf(model.Session{})}},
+					Required:   []string{"data"},
+				},
+				"UserResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": ref("User")},
+					Required:   []string{"data"},
+				},
+				"ClientResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": SchemaOf(model.Client{})},
+					Required:   []string{"data"},
+				},
+				"TokenPairResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"data": ref("TokenPair")},
+					Required:   []string{"data"},
+				},
+				"MessageResponse": {
+					Type:       "object",
+					Properties: map[string]*Schema{"message": {Type: "string"}},
+					Required:   []string{"message"},
+				},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token of this service or assertion of upstream identity provider"},
+				"apiKey": {Type: "apiKey", Name: "X-API-Key", In: "header"},
+				"basic":  {Type: "http", Scheme: "basic", Description: "User id and password, or client id and secret at the token endpoint"},
+			},
+		},
+	}
+}
+
+//callerSecurity are alternative ways for the caller to authenticate.
+//loginFormResponse is HTML login form of authorization endpoint.
+var loginFormResponse = Response{
+	Description: "Login form, CSRF token of it is set as cookie",
+	Headers:     map[string]Header{"Set-Cookie": {Schema: &Schema{Type: "string"}}},
+	Content:     map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}},
+}
+
+var callerSecurity = []map[string][]string{{"bearer": {}}, {"apiKey": {}}, {"basic": {}}}
+
+//tokenFilterParameters select refresh tokens for administrators.
+var tokenFilterParameters = []Parameter{
+	{Name: "user_id", In: "query", Schema: &Schema{Type: "string", Format: "uuid"}},
+	{Name: "client_id", In: "query", Schema: &Schema{Type: "string"}},
+	{Name: "session_id", In: "query", Description: "Family of refresh tokens obtained by rotation", Schema: &Schema{Type: "string"}},
+	{Name: "status", In: "query", Schema: &Schema{Type: "string", Enum: []string{"active", "used", "expired"}}},
+	{Name: "issued_after", In: "query", Description: "RFC 3339 time or unix seconds", Schema: &Schema{Type: "string"}},
+	{Name: "issued_before", In: "query", Description: "RFC 3339 time or unix seconds, tokens without recorded issuance time match too", Schema: &Schema{Type: "string"}},
+}
+
+//auditFilterParameters select audit events.
+var auditFilterParameters = []Parameter{
+	{Name: "type", In: "query", Schema: &Schema{Type: "string", Enum: []string{"issue", "rotate", "reuse_detected", "revoke", "bulk_revoke"}}},
+	{Name: "actor", In: "query", Description: "User id of the caller", Schema: &Schema{Type: "string", Format: "uuid"}},
+	{Name: "user_id", In: "query", Description: "User whose tokens are affected", Schema: &Schema{Type: "string", Format: "uuid"}},
+	{Name: "client_id", In: "query", Schema: &Schema{Type: "string"}},
+	{Name: "after", In: "query", Description: "RFC 3339 time or unix seconds", Schema: &Schema{Type: "string"}},
+	{Name: "before", In: "query", Description: "RFC 3339 time or unix seconds", Schema: &Schema{Type: "string"}},
+}
+
+//Headers of browser clients holding refresh token in cookie, they are only honoured if cookie mode is enabled.
+var (
+	tokenDeliveryParameter = Parameter{
+		Name:        "X-Token-Delivery",
+		In:          "header",
+		Description: "Set to cookie to keep refresh token in HttpOnly refresh_token cookie instead of request and response bodies",
+		Schema:      &Schema{Type: "string", Enum: []string{"cookie"}},
+	}
+	csrfTokenParameter = Parameter{
+		Name:        "X-CSRF-Token",
+		In:          "header",
+		Description: "Value of csrf_token cookie, required if refresh token is taken from refresh_token cookie",
+		Schema:      &Schema{Type: "string"},
+	}
+)
+
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+func jsonBody(s *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: jsonContent(s)}
+}
+
+//withProblems adds problem+json responses with given statuses and internal server error to responses.
+func withProblems(responses map[string]Response, statuses ...int) map[string]Response {
+	for _, status := range append(statuses, http.StatusInternalServerError) {
+		responses[strconv.Itoa(status)] = problemResponse(status)
+	}
+	return responses
+}
+
+//problemResponse describes problem details response with given status.
+func problemResponse(status int) Response {
+	resp := Response{
+		Description: http.StatusText(status),
+		Content:     map[string]MediaType{"application/problem+json": {Schema: ref("Problem")}},
+	}
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		resp.Headers = map[string]Header{
+			"WWW-Authenticate": {Description: "Bearer challenge as described in RFC 6750", Schema: &Schema{Type: "string"}},
+		}
+	}
+	if status == http.StatusTooManyRequests {
+		resp.Headers = map[string]Header{
+			"Retry-After": {Description: "Seconds to wait before retrying", Schema: &Schema{Type: "integer"}},
+		}
+	}
+	return resp
+}
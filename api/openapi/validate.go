@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+//Operation returns operation matching given method and request path.
+//As in the router, static path segments take precedence over parameters.
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	segments := strings.Split(path, "/")
+	var found *Operation
+	params := -1
+	for template, item := range d.Paths {
+		op, ok := item[strings.ToLower(method)]
+		if !ok || !matchPath(strings.Split(template, "/"), segments) {
+			continue
+		}
+		if n := strings.Count(template, "{"); params == -1 || n < params {
+			found, params = op, n
+		}
+	}
+	return found, found != nil
+}
+
+//matchPath reports whether path segments match template segments. Template segments in braces match any non empty segment.
+func matchPath(template, path []string) bool {
+	if len(template) != len(path) {
+		return false
+	}
+	for i := range template {
+		if strings.HasPrefix(template[i], "{") && strings.HasSuffix(template[i], "}") {
+			if path[i] == "" {
+				return false
+			}
+			continue
+		}
+		if template[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//CheckRoutes reports routes registered in router that are missing from the document and vice versa.
+func (d *Document) CheckRoutes(routes chi.Routes) error {
+	registered := map[string]bool{}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[strings.ToLower(method)+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for route := range registered {
+		parts := strings.SplitN(route, " ", 2)
+		if _, ok := d.Paths[parts[1]][parts[0]]; !ok {
+			problems = append(problems, fmt.Sprintf("route %s is not documented", route))
+		}
+	}
+	for path, item := range d.Paths {
+		for method := range item {
+			if !registered[method+" "+path] {
+				problems = append(problems, fmt.Sprintf("documented route %s %s is not registered", method, path))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("OpenAPI document does not match router: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+//Validator returns middleware validating requests and responses against the document.
+//Every mismatch is passed to report, requests are served regardless. It is meant to be used in tests.
+func (d *Document) Validator(report func(*http.Request, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, ok := d.Operation(r.Method, r.URL.Path)
+			if !ok {
+				report(r, fmt.Errorf("%s %s is not documented", r.Method, r.URL.Path))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err := d.validateRequest(op, r); err != nil {
+				report(r, fmt.Errorf("%s %s request: %w", r.Method, r.URL.Path, err))
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if err := d.validateResponse(op, rec.status, rec.Header(), rec.body.Bytes()); err != nil {
+				report(r, fmt.Errorf("%s %s response: %w", r.Method, r.URL.Path, err))
+			}
+		})
+	}
+}
+
+func (d *Document) validateRequest(op *Operation, r *http.Request) error {
+	if op.RequestBody == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("body is required")
+		}
+		return nil
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	return d.validateJSON(media.Schema, body)
+}
+
+func (d *Document) validateResponse(op *Operation, status int, header http.Header, body []byte) error {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok {
+			return fmt.Errorf("status %d is not documented", status)
+		}
+	}
+	for name := range resp.Headers {
+		if header.Get(name) == "" {
+			return fmt.Errorf("header %s is missing", name)
+		}
+	}
+	if len(resp.Content) == 0 {
+		return nil
+	}
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := resp.Content[contentType]
+	if !ok {
+		return fmt.Errorf("content type %q is not documented for status %d", contentType, status)
+	}
+	switch {
+	case contentType == "application/x-ndjson":
+		return d.validateNDJSON(media.Schema, body)
+	case strings.HasSuffix(contentType, "json"):
+		return d.validateJSON(media.Schema, body)
+	}
+	return nil
+}
+
+//validateNDJSON checks every line of newline delimited JSON against schema of a single value.
+func (d *Document) validateNDJSON(s *Schema, body []byte) error {
+	for i, line := range bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := d.validateJSON(s, line); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateJSON(s *Schema, body []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	return d.validateValue(s, v, "body")
+}
+
+//validateValue checks decoded JSON value against schema.
+func (d *Document) validateValue(s *Schema, v interface{}, path string) error {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if err := d.validateValue(prop, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		for i, item := range arr {
+			if err := d.validateValue(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return fmt.Errorf("%s must be one of %v", path, s.Enum)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+//recorder captures status code and body of the response while writing it through.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,4 @@
+//Package authpb contains protobuf definition of the gRPC API and code generated from it.
+package authpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative auth.proto
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"example.com/auth-service-go/internal/entity"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//errorCodes maps domain errors onto gRPC status codes.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{entity.ErrInvalidArgument, codes.InvalidArgument},
+	{entity.ErrMalformedToken, codes.InvalidArgument},
+	{entity.ErrInvalidSignature, codes.Unauthenticated},
+	{entity.ErrInvalidToken, codes.Unauthenticated},
+	{entity.ErrTokenExpired, codes.Unauthenticated},
+	{entity.ErrTokenMismatch, codes.Unauthenticated},
+	{entity.ErrUnauthenticated, codes.Unauthenticated},
+	{entity.ErrForbidden, codes.PermissionDenied},
+	{entity.ErrInvalidScope, codes.PermissionDenied},
+	{entity.ErrTokenNotFound, codes.NotFound},
+	{entity.ErrUserNotFound, codes.NotFound},
+	{entity.ErrUserExists, codes.AlreadyExists},
+	{entity.ErrTokenUsed, codes.FailedPrecondition},
+	{entity.ErrSessionLimit, codes.ResourceExhausted},
+	{entity.ErrOverloaded, codes.Unavailable},
+	{entity.ErrStorageUnavailable, codes.Unavailable},
+	{context.Canceled, codes.Canceled},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+}
+
+//statusFromError converts domain error into gRPC status error.
+//Unknown errors are reported as internal errors without details.
+func statusFromError(err error) error {
+	log.Println(err.Error())
+	var argErr *entity.ArgumentError
+	if errors.As(err, &argErr) {
+		return status.Error(codes.InvalidArgument, argErr.Message)
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return status.Error(e.code, e.err.Error())
+		}
+	}
+	return status.Error(codes.Internal, "Internal server error")
+}
@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"example.com/auth-service-go/api/proto/authpb"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+//Server implements gRPC AuthService over auth service.
+type Server struct {
+	authpb.UnimplementedAuthServiceServer
+	auth *service.AuthService
+}
+
+//NewServer returns a new Server.
+func NewServer(auth *service.AuthService) *Server {
+	return &Server{
+		auth: auth,
+	}
+}
+
+//Issue creates a new pair of tokens for the user.
+func (s *Server) Issue(ctx context.Context, req *authpb.IssueRequest) (*authpb.TokenPair, error) {
+	userID, err := entity.ParseUserID(req.GetUserId())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	tokenPair, err := s.auth.Issue(withDevice(ctx), credentials(ctx), userID, scopes(req.GetScopes()))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return toTokenPair(tokenPair), nil
+}
+
+//Refresh exchanges a pair of tokens issued together for a new pair.
+func (s *Server) Refresh(ctx context.Context, req *authpb.RefreshRequest) (*authpb.TokenPair, error) {
+	tokenPair, err := s.auth.Refresh(withDevice(ctx), req.GetAccessToken(), req.GetRefreshToken(), scopes(req.GetScopes()))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return toTokenPair(tokenPair), nil
+}
+
+//Revoke deletes particular refresh token.
+func (s *Server) Revoke(ctx context.Context, req *authpb.RevokeRequest) (*authpb.RevokeResponse, error) {
+	if err := s.auth.Revoke(withDevice(ctx), credentials(ctx), req.GetRefreshToken()); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &authpb.RevokeResponse{}, nil
+}
+
+//RevokeAll deletes all refresh tokens of the user.
+func (s *Server) RevokeAll(ctx context.Context, req *authpb.RevokeAllRequest) (*authpb.RevokeResponse, error) {
+	userID, err := entity.ParseUserID(req.GetUserId())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	if err := s.auth.RevokeAll(withDevice(ctx), credentials(ctx), userID); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &authpb.RevokeResponse{}, nil
+}
+
+//Validate checks access token and returns it`s claims.
+func (s *Server) Validate(ctx context.Context, req *authpb.ValidateRequest) (*authpb.ValidateResponse, error) {
+	claims, err := s.auth.Validate(ctx, req.GetAccessToken())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &authpb.ValidateResponse{
+		UserId:      claims.User_id,
+		RefreshUuid: claims.Refresh_uuid,
+		ExpiresAt:   claims.ExpiresAt,
+		Scopes:      entity.ParseScope(claims.Scope),
+		Roles:       claims.Roles,
+		ClientId:    claims.Client_id,
+		Audience:    claims.Audience,
+		Actors:      actors(claims.Act),
+	}, nil
+}
+
+//actors flattens act claim chain into subjects of the actors, the current actor first.
+func actors(act *entity.Actor) []string {
+	var subs []string
+	for ; act != nil; act = act.Act {
+		subs = append(subs, act.Sub)
+	}
+	return subs
+}
+
+//credentials extracts caller credentials from authorization and x-api-key metadata.
+func credentials(ctx context.Context) service.Credentials {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	return service.CredentialsFromHeaders(first("authorization"), first("x-api-key"))
+}
+
+//withDevice returns ctx carrying the peer of the call and it`s user-agent metadata.
+func withDevice(ctx context.Context) context.Context {
+	device := service.Device{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			device.UserAgent = values[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		device.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(device.IP); err == nil {
+			device.IP = host
+		}
+	}
+	return service.ContextWithDevice(ctx, device)
+}
+
+//toTokenPair converts pair of tokens into protobuf message.
+func toTokenPair(tokenPair *service.TokenPair) *authpb.TokenPair {
+	return &authpb.TokenPair{
+		AccessToken:           tokenPair.AccessToken,
+		RefreshToken:          tokenPair.RefreshToken,
+		AccessTokenExpiresAt:  tokenPair.AccessTokenExpiresAt,
+		RefreshTokenExpiresAt: tokenPair.RefreshTokenExpiresAt,
+		Scopes:                tokenPair.Scopes,
+	}
+}
+
+//scopes converts requested scopes of protobuf message, empty list requests default scopes.
+func scopes(requested []string) []string {
+	return entity.ParseScope(entity.FormatScope(requested))
+}
@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"example.com/auth-service-go/api/proto/authpb"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"example.com/auth-service-go/internal/service/servicetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	adminKey = "admin key"
+	adminID  = "5d1c9c1e-3c4b-4f5e-9a77-1c2d3e4f5a6b"
+	userKey  = "user key"
+	userID   = "0b4f7d2e-9a3c-4e51-8f6d-2c7a1b9e0d34"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("TOKEN_SECRET", "test token secret")
+	os.Exit(m.Run())
+}
+
+//dial serves Server over in-memory connection and returns client of it together with the repositories it works on.
+func dial(t *testing.T) (authpb.AuthServiceClient, *servicetest.Repositories) {
+	repos := servicetest.New()
+	entity.UseWatermarks(repos.Watermarks)
+	apiKeys, err := service.NewAPIKeyAuthenticator(adminKey + ":" + adminID + ":" + service.ScopeAdmin + ";" + userKey + ":" + userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := repos.AuthService(service.Authenticators{apiKeys, service.AccessTokenAuthenticator{}})
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	authpb.RegisterAuthServiceServer(s, NewServer(auth))
+	go s.Serve(lis)
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(), grpc.WithUserAgent("rpc-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+		entity.UseWatermarks(nil)
+	})
+	return authpb.NewAuthServiceClient(conn), repos
+}
+
+//as returns ctx of the call authenticated by API key.
+func as(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func wantCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("got code %s (%v), want %s", got, err, want)
+	}
+}
+
+func TestServer(t *testing.T) {
+	client, repos := dial(t)
+
+	issued, err := client.Issue(as(adminKey), &authpb.IssueRequest{UserId: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validated, err := client.Validate(context.Background(), &authpb.ValidateRequest{AccessToken: issued.AccessToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validated.UserId != userID {
+		t.Errorf("got user %q, want %q", validated.UserId, userID)
+	}
+
+	refreshed, err := client.Refresh(context.Background(), &authpb.RefreshRequest{AccessToken: issued.AccessToken, RefreshToken: issued.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Refresh(context.Background(), &authpb.RefreshRequest{AccessToken: issued.AccessToken, RefreshToken: issued.RefreshToken})
+	wantCode(t, err, codes.FailedPrecondition)
+	_, err = client.Refresh(context.Background(), &authpb.RefreshRequest{AccessToken: refreshed.AccessToken, RefreshToken: issued.AccessToken})
+	wantCode(t, err, codes.InvalidArgument)
+
+	_, err = client.Revoke(context.Background(), &authpb.RevokeRequest{RefreshToken: refreshed.RefreshToken})
+	wantCode(t, err, codes.Unauthenticated)
+	if _, err := client.Revoke(as(userKey), &authpb.RevokeRequest{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Revoke(as(userKey), &authpb.RevokeRequest{RefreshToken: refreshed.RefreshToken})
+	wantCode(t, err, codes.NotFound)
+
+	if _, err := client.Issue(as(userKey), &authpb.IssueRequest{UserId: userID}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.RevokeAll(as(userKey), &authpb.RevokeAllRequest{UserId: adminID})
+	wantCode(t, err, codes.PermissionDenied)
+	if _, err := client.RevokeAll(as(adminKey), &authpb.RevokeAllRequest{UserId: userID}); err != nil {
+		t.Fatal(err)
+	}
+
+	//Every audited call records the peer and user agent it came from.
+	revoked := 0
+	for _, event := range repos.Audit.Events() {
+		if event.Type != entity.AuditRevoke || event.Result != entity.AuditResultSuccess {
+			continue
+		}
+		revoked++
+		if event.IP == "" || event.UserAgent == "" {
+			t.Errorf("got revoke event without device: %+v", event)
+		}
+	}
+	if revoked != 2 {
+		t.Errorf("got %d successful revoke events, want 2", revoked)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	client, _ := dial(t)
+	issued, err := client.Issue(as(adminKey), &authpb.IssueRequest{UserId: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"issue with malformed user id", func() error {
+			_, err := client.Issue(as(adminKey), &authpb.IssueRequest{UserId: "user"})
+			return err
+		}, codes.InvalidArgument},
+		{"issue without credentials", func() error {
+			_, err := client.Issue(context.Background(), &authpb.IssueRequest{UserId: userID})
+			return err
+		}, codes.Unauthenticated},
+		{"issue for another user", func() error {
+			_, err := client.Issue(as(userKey), &authpb.IssueRequest{UserId: adminID})
+			return err
+		}, codes.PermissionDenied},
+		{"validate empty token", func() error {
+			_, err := client.Validate(context.Background(), &authpb.ValidateRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"validate malformed token", func() error {
+			_, err := client.Validate(context.Background(), &authpb.ValidateRequest{AccessToken: "token"})
+			return err
+		}, codes.InvalidArgument},
+		{"validate tampered token", func() error {
+			_, err := client.Validate(context.Background(), &authpb.ValidateRequest{AccessToken: issued.AccessToken + "a"})
+			return err
+		}, codes.Unauthenticated},
+		{"revoke all with malformed user id", func() error {
+			_, err := client.RevokeAll(as(adminKey), &authpb.RevokeAllRequest{UserId: "user"})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantCode(t, tt.call(), tt.want)
+		})
+	}
+}
+
+func TestStatusFromError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{"argument error", &entity.ArgumentError{Message: "User id is empty"}, codes.InvalidArgument, "User id is empty"},
+		{"wrapped domain error", fmt.Errorf("%w: connection refused", entity.ErrStorageUnavailable), codes.Unavailable, entity.ErrStorageUnavailable.Error()},
+		{"client has gone", fmt.Errorf("Error hashing password: %w", context.Canceled), codes.Canceled, context.Canceled.Error()},
+		{"deadline exceeded", fmt.Errorf("Error hashing password: %w", context.DeadlineExceeded), codes.DeadlineExceeded, context.DeadlineExceeded.Error()},
+		{"unknown error", errors.New("unexpected"), codes.Internal, "Internal server error"},
+	}
+	for _, e := range errorCodes {
+		tests = append(tests, struct {
+			name    string
+			err     error
+			code    codes.Code
+			message string
+		}{e.err.Error(), e.err, e.code, e.err.Error()})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := status.Convert(statusFromError(tt.err))
+			if got.Code() != tt.code || got.Message() != tt.message {
+				t.Errorf("got %s %q, want %s %q", got.Code(), got.Message(), tt.code, tt.message)
+			}
+		})
+	}
+}
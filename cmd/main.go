@@ -2,15 +2,36 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"runtime"
+	"strconv"
 	"time"
 
 	"example.com/auth-service-go/api/handler"
+	"example.com/auth-service-go/api/openapi"
+	"example.com/auth-service-go/api/proto/authpb"
+	"example.com/auth-service-go/api/rpc"
 	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
 	"example.com/auth-service-go/internal/infrastructure/database"
+	"example.com/auth-service-go/internal/infrastructure/hashing"
+	auditmongo "example.com/auth-service-go/internal/repository/audit/mongo"
+	clientmongo "example.com/auth-service-go/internal/repository/client/mongo"
+	codemongo "example.com/auth-service-go/internal/repository/code/mongo"
+	devicemongo "example.com/auth-service-go/internal/repository/device/mongo"
+	ratelimitmemory "example.com/auth-service-go/internal/repository/ratelimit/memory"
+	ratelimitmongo "example.com/auth-service-go/internal/repository/ratelimit/mongo"
 	mongo "example.com/auth-service-go/internal/repository/token/mongo"
+	usermongo "example.com/auth-service-go/internal/repository/user/mongo"
+	watermarkmongo "example.com/auth-service-go/internal/repository/watermark/mongo"
+	"example.com/auth-service-go/internal/service"
 	"github.com/go-chi/chi"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -26,23 +47,154 @@ func run() error {
 	mongoDB, ctx := database.NewMongoClient(ctx, cfg)
 	defer mongoDB.Disconnect(ctx)
 
-	tokenMongoRepo := mongo.NewTokenRepository(mongoDB, "tokens")
+	sessionLimit, err := entity.ParseSessionLimit(cfg.MaxUserSessions, cfg.MaxClientSessions, cfg.SessionLimitPolicy)
+	if err != nil {
+		return err
+	}
+	hasher, err := hashPool(cfg)
+	if err != nil {
+		return err
+	}
+	tokenMongoRepo := mongo.NewTokenRepository(mongoDB, "tokens", "audit_events", "session_locks", sessionLimit, hasher)
+	userMongoRepo := usermongo.NewUserRepository(mongoDB, "users")
+	clientMongoRepo := clientmongo.NewClientRepository(mongoDB, "clients")
+	codeMongoRepo := codemongo.NewCodeRepository(mongoDB, "authorization_codes")
+	deviceMongoRepo := devicemongo.NewDeviceRepository(mongoDB, "device_codes")
+	watermarkTTL, err := time.ParseDuration(cfg.WatermarkCacheTTL)
+	if err != nil || watermarkTTL < 0 {
+		return fmt.Errorf("Watermark cache TTL must be a non-negative duration, not %q", cfg.WatermarkCacheTTL)
+	}
+	watermarks := service.NewWatermarkCache(watermarkmongo.NewWatermarkRepository(mongoDB, "watermarks"), watermarkTTL)
+	entity.UseWatermarks(watermarks)
+	auditMongoRepo := auditmongo.NewAuditRepository(mongoDB, "audit_events")
+	apiKeys, err := service.NewAPIKeyAuthenticator(cfg.APIKeys)
+	if err != nil {
+		return err
+	}
+	authenticator := service.Authenticators{
+		apiKeys,
+		service.AccessTokenAuthenticator{Audience: cfg.Issuer},
+		&service.AssertionAuthenticator{Secret: []byte(cfg.AssertionSecret)},
+		&service.PasswordAuthenticator{Verifier: service.NewUserPasswords(userMongoRepo, hasher)},
+	}
+	idTokenKey, err := signingKey(cfg.IDTokenKey)
+	if err != nil {
+		return err
+	}
+	policy, err := service.NewScopePolicy(cfg.RoleScopes)
+	if err != nil {
+		return err
+	}
+	if cfg.RefreshTokenFormat != entity.RefreshTokenJWT && cfg.RefreshTokenFormat != entity.RefreshTokenOpaque {
+		return fmt.Errorf("Refresh token format must be jwt or opaque, not %q", cfg.RefreshTokenFormat)
+	}
+	authService := service.NewAuthService(tokenMongoRepo, userMongoRepo, clientMongoRepo, codeMongoRepo, deviceMongoRepo, watermarks, auditMongoRepo, authenticator,
+		service.WithIssuer(cfg.Issuer), service.WithIDTokenKey(idTokenKey), service.WithScopePolicy(policy), service.WithHasher(hasher),
+		service.WithRefreshTokenFormat(cfg.RefreshTokenFormat))
+	limiter, err := rateLimiter(mongoDB, cfg)
+	if err != nil {
+		return err
+	}
+	cookieMode, err := strconv.ParseBool(cfg.RefreshTokenCookie)
+	if err != nil {
+		return fmt.Errorf("Refresh token cookie must be true or false, not %q", cfg.RefreshTokenCookie)
+	}
+	trustedProxies, err := strconv.Atoi(cfg.TrustedProxies)
+	if err != nil || trustedProxies < 0 {
+		return fmt.Errorf("Number of trusted proxies must not be negative, not %q", cfg.TrustedProxies)
+	}
 	router := chi.NewRouter()
 
 	handler := handler.New(ctx, router)
-	handler.InitAuthRoutes(tokenMongoRepo)
+	handler.CookieMode = cookieMode
+	handler.TrustedProxies = trustedProxies
+	handler.InitAuthRoutes(authService, limiter)
+	handler.InitOAuthRoutes(authService, limiter)
+	handler.InitOIDCRoutes(authService)
+	handler.InitAdminRoutes(authService, limiter)
+	doc := openapi.New()
+	handler.InitDocsRoutes(doc)
 	//Placeholder for main app page to replace default heroku`s one.
-	handler.Router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	handler.Router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("App is running"))
 	})
+	//Make sure OpenAPI document describes exactly the registered routes.
+	if err := doc.CheckRoutes(handler.Router); err != nil {
+		return err
+	}
 
 	s := http.Server{
 		Addr:         ":" + cfg.Port,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 		Handler:      handler.Router,
+		ConnContext:  handler.ConnContext,
+		BaseContext:  func(net.Listener) context.Context { return handler.Context },
+	}
+
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		return err
 	}
+	grpcServer := grpc.NewServer()
+	authpb.RegisterAuthServiceServer(grpcServer, rpc.NewServer(authService))
 
-	log.Println("Server is running")
-	return s.ListenAndServe()
+	errs := make(chan error, 2)
+	go func() {
+		log.Println("gRPC server is running")
+		errs <- grpcServer.Serve(lis)
+	}()
+	go func() {
+		log.Println("Server is running")
+		errs <- s.ListenAndServe()
+	}()
+	return <-errs
+}
+
+//signingKey parses ID token signing key or generates ephemeral one if it is not configured.
+func signingKey(pem string) (*entity.SigningKey, error) {
+	if pem != "" {
+		return entity.ParseSigningKey(pem)
+	}
+	log.Println("ID_TOKEN_KEY is not set, ID tokens are signed with ephemeral key and become invalid on restart")
+	return entity.GenerateSigningKey()
+}
+
+//rateLimiter creates rate limiter of auth, oauth and admin routes with buckets kept in configured backend.
+func rateLimiter(cl *mongodriver.Client, cfg *config.Config) (*service.RateLimiter, error) {
+	limits, err := entity.ParseRateLimits(cfg.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.RateLimitBackend {
+	case "memory":
+		return service.NewRateLimiter(ratelimitmemory.NewRateLimitRepository(), limits), nil
+	case "mongo":
+		return service.NewRateLimiter(ratelimitmongo.NewRateLimitRepository(cl, "rate_limits"), limits), nil
+	}
+	return nil, fmt.Errorf("Rate limit backend must be memory or mongo, not %q", cfg.RateLimitBackend)
+}
+
+//hashPool starts pool of bcrypt hashing workers, one per CPU unless configured otherwise.
+func hashPool(cfg *config.Config) (*hashing.Pool, error) {
+	cost, err := strconv.Atoi(cfg.BcryptCost)
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("Bcrypt cost must be between %d and %d, not %q", bcrypt.MinCost, bcrypt.MaxCost, cfg.BcryptCost)
+	}
+	workers := runtime.NumCPU()
+	if cfg.HashWorkers != "" {
+		workers, err = strconv.Atoi(cfg.HashWorkers)
+		if err != nil || workers <= 0 {
+			return nil, fmt.Errorf("Number of hash workers must be positive, not %q", cfg.HashWorkers)
+		}
+	}
+	queue, err := strconv.Atoi(cfg.HashQueue)
+	if err != nil || queue < 0 {
+		return nil, fmt.Errorf("Hash queue length must not be negative, not %q", cfg.HashQueue)
+	}
+	timeout, err := time.ParseDuration(cfg.HashTimeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("Hash timeout must be a positive duration, not %q", cfg.HashTimeout)
+	}
+	return hashing.NewPool(workers, queue, timeout, cost), nil
 }
@@ -0,0 +1,81 @@
+//Command notbefore sets not before watermark directly in the database, so tokens can be revoked
+//even if the service or it`s admin credentials are compromised.
+//
+//Usage:
+//
+//	notbefore [-user <user id>] [-at <RFC 3339 time or unix seconds>]
+//
+//Without -user the watermark applies to tokens of everyone, without -at it is the current time.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/infrastructure/database"
+	watermarkmongo "example.com/auth-service-go/internal/repository/watermark/mongo"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	user := flag.String("user", "", "id of the user whose tokens are revoked, tokens of everyone are revoked if empty")
+	at := flag.String("at", "", "tokens issued before this RFC 3339 time or unix seconds are revoked, current time if empty")
+	flag.Parse()
+
+	var userID entity.UserID
+	if *user != "" {
+		var err error
+		if userID, err = entity.ParseUserID(*user); err != nil {
+			return err
+		}
+	}
+	notBefore, err := parseTime(*at)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.New()
+	ctx := context.Background()
+	mongoDB, ctx := database.NewMongoClient(ctx, cfg)
+	defer mongoDB.Disconnect(ctx)
+
+	watermarks := watermarkmongo.NewWatermarkRepository(mongoDB, "watermarks")
+	notBefore, err = watermarks.SetNotBefore(ctx, userID, notBefore)
+	if err != nil {
+		return err
+	}
+	log.Printf("Tokens issued before %s are revoked", time.Unix(notBefore, 0).UTC().Format(time.RFC3339))
+	return nil
+}
+
+//parseTime parses RFC 3339 time or unix seconds into unix seconds.
+//Empty string is the current time.
+func parseTime(raw string) (int64, error) {
+	now := time.Now()
+	if raw == "" {
+		return now.Unix(), nil
+	}
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return 0, fmt.Errorf("Time must be RFC 3339 time or unix seconds, not %q", raw)
+		}
+		seconds = t.Unix()
+	}
+	if seconds > now.Unix() {
+		return 0, fmt.Errorf("Time must not be in the future")
+	}
+	return seconds, nil
+}
@@ -15,7 +15,43 @@ var (
 //Config holds environment variables.
 type Config struct {
 	Port        string
+	GRPCPort    string
 	TokenSecret string
+	//Issuer is a base URL of the service, e.g. https://auth.example.com.
+	Issuer string
+	//IDTokenKey is a PEM encoded RSA private key ID tokens are signed with. Ephemeral key is generated if it is empty.
+	IDTokenKey string `json:"-"`
+	//APIKeys is a semicolon separated list of key:user_id:scope,scope entries.
+	APIKeys string `json:"-"`
+	//RoleScopes is a semicolon separated list of role:scope,scope entries deciding which scopes users with the role may be granted.
+	RoleScopes string
+	//AssertionSecret is a secret of upstream identity provider assertions. Assertions are not accepted if it is empty.
+	AssertionSecret string `json:"-"`
+	//MaxUserSessions and MaxClientSessions limit active sessions of the user and of the user with one client, empty means no limit.
+	//SessionLimitPolicy is either reject or evict.
+	MaxUserSessions    string
+	MaxClientSessions  string
+	SessionLimitPolicy string
+	//RateLimits is a semicolon separated list of route=requests/period entries, e.g. "POST /auth/login=5/1m;*=60/1m".
+	//RateLimitBackend is either memory or mongo, the latter shares limits between instances of the service.
+	RateLimits       string
+	RateLimitBackend string
+	//TrustedProxies is a number of reverse proxies in front of the service appending client addresses to X-Forwarded-For,
+	//heroku router is the only one by default.
+	TrustedProxies string
+	//BcryptCost is a cost of refresh token and client secret hashes. HashWorkers generate and compare them and password hashes
+	//concurrently, empty means number of CPUs, HashQueue more hashes may wait for a worker at most HashTimeout before the request fails.
+	BcryptCost  string
+	HashWorkers string
+	HashQueue   string
+	HashTimeout string
+	//RefreshTokenFormat is a format of issued refresh tokens, either jwt or opaque.
+	RefreshTokenFormat string
+	//RefreshTokenCookie enables delivery of refresh tokens to browser clients in HttpOnly cookies.
+	RefreshTokenCookie string
+	//WatermarkCacheTTL is how long not before watermarks read from the database are reused,
+	//watermarks set on other instances of the service take effect after at most this time.
+	WatermarkCacheTTL string
 
 	DbUser     string
 	DbPassword string
@@ -31,12 +67,31 @@ func New() *Config {
 			dev()
 		}
 		config = &Config{
-			Port:        getEnv("PORT"),
-			TokenSecret: getEnv("TOKEN_SECRET"),
-			DbUser:      getEnv("DB_USER"),
-			DbPassword:  getEnv("DB_PASSWORD"),
-			DbName:      getEnv("DB_NAME"),
-			DbPort:      getEnv("DB_PORT"),
+			Port:               getEnv("PORT"),
+			GRPCPort:           getEnv("GRPC_PORT"),
+			TokenSecret:        getEnv("TOKEN_SECRET"),
+			Issuer:             getEnv("ISSUER"),
+			IDTokenKey:         lookupEnv("ID_TOKEN_KEY", ""),
+			APIKeys:            lookupEnv("API_KEYS", ""),
+			RoleScopes:         lookupEnv("ROLE_SCOPES", ""),
+			AssertionSecret:    lookupEnv("ASSERTION_SECRET", ""),
+			MaxUserSessions:    lookupEnv("MAX_USER_SESSIONS", ""),
+			MaxClientSessions:  lookupEnv("MAX_CLIENT_SESSIONS", ""),
+			SessionLimitPolicy: lookupEnv("SESSION_LIMIT_POLICY", "reject"),
+			RateLimits:         lookupEnv("RATE_LIMITS", "POST /auth/login=10/1m;POST /oauth/authorize=10/1m;*=120/1m"),
+			RateLimitBackend:   lookupEnv("RATE_LIMIT_BACKEND", "memory"),
+			TrustedProxies:     lookupEnv("TRUSTED_PROXIES", "1"),
+			BcryptCost:         lookupEnv("BCRYPT_COST", "12"),
+			HashWorkers:        lookupEnv("HASH_WORKERS", ""),
+			HashQueue:          lookupEnv("HASH_QUEUE", "100"),
+			HashTimeout:        lookupEnv("HASH_TIMEOUT", "2s"),
+			RefreshTokenFormat: lookupEnv("REFRESH_TOKEN_FORMAT", "jwt"),
+			RefreshTokenCookie: lookupEnv("REFRESH_TOKEN_COOKIE", "false"),
+			WatermarkCacheTTL:  lookupEnv("WATERMARK_CACHE_TTL", "5s"),
+			DbUser:             getEnv("DB_USER"),
+			DbPassword:         getEnv("DB_PASSWORD"),
+			DbName:             getEnv("DB_NAME"),
+			DbPort:             getEnv("DB_PORT"),
 		}
 
 		configJSON, err := json.MarshalIndent(config, "", " ")
@@ -59,3 +114,11 @@ func getEnv(key string) string {
 	}
 	return value
 }
+
+//lookupEnv is an helper function to get optional environment variable with fallback value.
+func lookupEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
@@ -6,8 +6,28 @@ import "os"
 func dev() {
 	//API environment variables
 	os.Setenv("PORT", "8080")
+	os.Setenv("GRPC_PORT", "9090")
+	os.Setenv("ISSUER", "http://localhost:8080")
 	//JWT secret
 	os.Setenv("TOKEN_SECRET", "tokensecrettokensecret")
+	//Authentication of callers
+	os.Setenv("API_KEYS", "devadminkey:00000000-0000-0000-0000-000000000000:admin")
+	os.Setenv("ROLE_SCOPES", "admin:admin,read,write;user:read,write")
+	os.Setenv("ASSERTION_SECRET", "assertionsecretassertionsecret")
+	//Session limits
+	os.Setenv("MAX_USER_SESSIONS", "10")
+	os.Setenv("MAX_CLIENT_SESSIONS", "5")
+	os.Setenv("SESSION_LIMIT_POLICY", "evict")
+	//Rate limits, there is no proxy in front of the service in development
+	os.Setenv("RATE_LIMITS", "POST /auth/login=5/1m;POST /auth/register=5/1m;POST /oauth/authorize=5/1m;*=60/1m")
+	os.Setenv("RATE_LIMIT_BACKEND", "memory")
+	os.Setenv("TRUSTED_PROXIES", "0")
+	//Hashing of refresh tokens and client secrets
+	os.Setenv("BCRYPT_COST", "10")
+	os.Setenv("HASH_QUEUE", "50")
+	os.Setenv("HASH_TIMEOUT", "2s")
+	os.Setenv("REFRESH_TOKEN_FORMAT", "opaque")
+	os.Setenv("REFRESH_TOKEN_COOKIE", "true")
 	//Database environment variables
 	os.Setenv("DB_USER", "admin")
 	os.Setenv("DB_PASSWORD", "password")
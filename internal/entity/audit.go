@@ -0,0 +1,80 @@
+package entity
+
+import "time"
+
+//Types of audit events.
+const (
+	//AuditIssue is recorded when refresh token is issued, both for a new session and in place of a rotated one,
+	//and when access token is issued without refresh token by client credentials and token exchange grants.
+	AuditIssue = "issue"
+	//AuditRotate is recorded when refresh token is marked used in exchange for a new pair.
+	AuditRotate = "rotate"
+	//AuditReuseDetected is recorded when already used refresh token is presented again.
+	AuditReuseDetected = "reuse_detected"
+	//AuditRevoke is recorded when refresh tokens of a user, session or client are deleted.
+	AuditRevoke = "revoke"
+	//AuditBulkRevoke is recorded when refresh tokens matching admin filter are deleted
+	//and when not before watermark revokes all tokens of the user or of everyone.
+	AuditBulkRevoke = "bulk_revoke"
+)
+
+//AuditResultSuccess is a result of event that changed state, failed events keep the error.
+const AuditResultSuccess = "success"
+
+//AuditEvent is an entry of append-only audit trail of refresh token lifecycle.
+type AuditEvent struct {
+	ID   string `bson:"_id"`
+	Type string `bson:"type"`
+	//Time is unix time of the event.
+	Time int64 `bson:"time"`
+	//Actor is the authenticated caller, it is the user itself when tokens are presented by their holder.
+	Actor UserID `bson:"actor,omitempty"`
+	//UserID is the user whose tokens are affected.
+	UserID    UserID `bson:"user_id,omitempty"`
+	ClientID  string `bson:"client_id,omitempty"`
+	SessionID string `bson:"session_id,omitempty"`
+	//TokenIDs are ids of refresh tokens issued or rotated by the event, or of the particular token deleted by it.
+	//Count is a number of refresh tokens deleted by revoke and bulk_revoke events.
+	TokenIDs []string `bson:"token_ids,omitempty"`
+	Count    int64    `bson:"count,omitempty"`
+	//Filter is the admin filter of bulk_revoke event, it`s user, client and session are the fields of the event.
+	Filter    *AuditTokenFilter `bson:"filter,omitempty"`
+	IP        string            `bson:"ip,omitempty"`
+	UserAgent string            `bson:"user_agent,omitempty"`
+	//Result is AuditResultSuccess or the error the operation failed with.
+	Result string `bson:"result"`
+}
+
+//AuditTokenFilter is a part of TokenFilter recorded in bulk_revoke event.
+type AuditTokenFilter struct {
+	//IssuedAfter and IssuedBefore are unix times.
+	IssuedAfter  int64  `bson:"issued_after,omitempty"`
+	IssuedBefore int64  `bson:"issued_before,omitempty"`
+	Status       string `bson:"status,omitempty"`
+}
+
+//Failed returns a copy of the event which failed with err.
+func (e AuditEvent) Failed(err error) AuditEvent {
+	e.Result = err.Error()
+	return e
+}
+
+//AuditFilter selects audit events, zero fields match any event.
+type AuditFilter struct {
+	Type     string
+	Actor    UserID
+	UserID   UserID
+	ClientID string
+	//After and Before bound time of the event.
+	After  time.Time
+	Before time.Time
+}
+
+//Validate checks that type of the filter is known.
+func (f *AuditFilter) Validate() error {
+	switch f.Type {
+	case "", AuditIssue, AuditRotate, AuditReuseDetected, AuditRevoke, AuditBulkRevoke:
+		return nil
+	}
+	return &ArgumentError{Message: "Type must be issue, rotate, reuse_detected, revoke or bulk_revoke"}
+}
@@ -0,0 +1,252 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+//OAuth 2.0 grant types clients may be allowed to use.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+//Client authentication methods at the token endpoint, see RFC 7591 section 2.
+const (
+	ClientAuthSecretBasic   = "client_secret_basic"
+	ClientAuthSecretPost    = "client_secret_post"
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+	//ClientAuthNone is used by public clients which can not keep a secret.
+	ClientAuthNone = "none"
+)
+
+//ClientAssertionType is a value of client_assertion_type parameter for private_key_jwt, see RFC 7523 section 2.2.
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+//clientSecretLength is a number of random bytes in generated client secret.
+const clientSecretLength = 32
+
+//Client is an representation of OAuth 2.0 client that will be stored in mongoDB.
+type Client struct {
+	ID string `bson:"_id"`
+	//SecretHash is a bcrypt hash of client secret. It is set for client_secret_basic and client_secret_post clients.
+	SecretHash string `bson:"secret_hash,omitempty"`
+	//PublicKey is a PEM encoded RSA or ECDSA public key. It is set for private_key_jwt clients.
+	PublicKey    string   `bson:"public_key,omitempty"`
+	AuthMethod   string   `bson:"auth_method"`
+	GrantTypes   []string `bson:"grant_types"`
+	RedirectURIs []string `bson:"redirect_uris"`
+	Scopes       []string `bson:"scopes"`
+	//AccessTokenTTL and RefreshTokenTTL override default lifetimes of tokens issued to the client, in seconds.
+	AccessTokenTTL  int64 `bson:"access_token_ttl,omitempty"`
+	RefreshTokenTTL int64 `bson:"refresh_token_ttl,omitempty"`
+	CreatedAt       int64 `bson:"created_at"`
+}
+
+//Validate checks client registration metadata.
+func (c *Client) Validate() error {
+	switch c.AuthMethod {
+	case ClientAuthSecretBasic, ClientAuthSecretPost, ClientAuthNone:
+	case ClientAuthPrivateKeyJWT:
+		if _, err := parsePublicKey(c.PublicKey); err != nil {
+			return &ArgumentError{Message: "Public key must be PEM encoded RSA or ECDSA public key"}
+		}
+	default:
+		return &ArgumentError{Message: fmt.Sprintf("Client authentication method %q is not supported", c.AuthMethod)}
+	}
+	if len(c.GrantTypes) == 0 {
+		return &ArgumentError{Message: "Client must be allowed at least one grant type"}
+	}
+	for _, grantType := range c.GrantTypes {
+		switch grantType {
+		case GrantAuthorizationCode, GrantRefreshToken, GrantDeviceCode:
+		case GrantClientCredentials, GrantTokenExchange:
+			if c.AuthMethod == ClientAuthNone {
+				return &ArgumentError{Message: fmt.Sprintf("Public client can not use %s grant", grantType)}
+			}
+		default:
+			return &ArgumentError{Message: fmt.Sprintf("Grant type %q is not supported", grantType)}
+		}
+	}
+	for _, redirectURI := range c.RedirectURIs {
+		//See RFC 6749 section 3.1.2.
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return &ArgumentError{Message: fmt.Sprintf("Redirect URI %q must be an absolute URI without fragment", redirectURI)}
+		}
+	}
+	if c.AccessTokenTTL < 0 || c.RefreshTokenTTL < 0 {
+		return &ArgumentError{Message: "Token lifetimes must not be negative"}
+	}
+	return nil
+}
+
+//IsConfidential reports whether client authenticates with a secret or a key.
+func (c *Client) IsConfidential() bool {
+	return c.AuthMethod != ClientAuthNone
+}
+
+//AllowsGrant reports whether client may use the grant type.
+func (c *Client) AllowsGrant(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+//AllowsRedirectURI reports whether redirect URI is registered for the client. URIs are compared exactly.
+func (c *Client) AllowsRedirectURI(redirectURI string) bool {
+	return contains(c.RedirectURIs, redirectURI)
+}
+
+//AllowsScopes reports whether all the scopes are registered for the client. Scope openid is allowed for every client.
+func (c *Client) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if scope != ScopeOpenID && !contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+//TokenParams returns parameters of tokens issued to the client on behalf of the user at given time.
+func (c *Client) TokenParams(userID UserID, now time.Time) TokenParams {
+	return TokenParams{
+		UserID:          userID,
+		ClientID:        c.ID,
+		IssuedAt:        now,
+		AccessTokenTTL:  time.Duration(c.AccessTokenTTL) * time.Second,
+		RefreshTokenTTL: time.Duration(c.RefreshTokenTTL) * time.Second,
+	}
+}
+
+//CompareSecret checks client secret against stored hash.
+//It returns ErrUnauthenticated if secret does not match or client has no secret.
+func (c *Client) CompareSecret(secret string) error {
+	if c.SecretHash == "" || secret == "" {
+		return ErrUnauthenticated
+	}
+	return ComparePasswordHash(c.SecretHash, secret)
+}
+
+//GenerateClientSecret generates a new random client secret.
+func GenerateClientSecret() (string, error) {
+	secret := make([]byte, clientSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		log.Println(err.Error())
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+//clientAssertionClaims are claims of private_key_jwt client assertion, see RFC 7523 section 3.
+type clientAssertionClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	ID        string   `json:"jti"`
+}
+
+//Valid implements jwt.Claims. Claims are checked by VerifyAssertion instead.
+func (c *clientAssertionClaims) Valid() error {
+	return nil
+}
+
+//audience is an aud claim which is either a single string or an array of strings.
+type audience []string
+
+//UnmarshalJSON implements json.Unmarshaler.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+//ClientAssertionIssuer returns iss claim of client assertion without verifying it.
+func ClientAssertionIssuer(assertion string) (string, error) {
+	claims := &clientAssertionClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(assertion, claims); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnauthenticated, err.Error())
+	}
+	return claims.Issuer, nil
+}
+
+//VerifyAssertion checks private_key_jwt client assertion signed with client key.
+//Assertion must be issued by the client about itself for one of the audiences and must not be expired.
+//It returns ErrUnauthenticated if assertion is not valid.
+func (c *Client) VerifyAssertion(assertion string, now time.Time, audiences ...string) error {
+	if c.PublicKey == "" || assertion == "" {
+		return ErrUnauthenticated
+	}
+	key, err := parsePublicKey(c.PublicKey)
+	if err != nil {
+		return err
+	}
+	claims := &clientAssertionClaims{}
+	_, err = jwt.ParseWithClaims(assertion, claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+			return key, nil
+		}
+		return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnauthenticated, err.Error())
+	}
+	if claims.Issuer != c.ID || claims.Subject != c.ID {
+		return fmt.Errorf("%w: assertion must be issued by the client about itself", ErrUnauthenticated)
+	}
+	if !containsAny(claims.Audience, audiences) {
+		return fmt.Errorf("%w: assertion audience is not valid", ErrUnauthenticated)
+	}
+	if claims.ExpiresAt == 0 || claims.ExpiresAt < now.Unix() {
+		return fmt.Errorf("%w: assertion is expired or has no exp claim", ErrUnauthenticated)
+	}
+	return nil
+}
+
+//parsePublicKey parses PEM encoded RSA or ECDSA public key.
+func parsePublicKey(pem string) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pem)); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM([]byte(pem)); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("Public key is not valid")
+}
+
+//contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//containsAny reports whether any of items is in list.
+func containsAny(list []string, items []string) bool {
+	for _, item := range items {
+		if contains(list, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+//AuthorizationCodeTTL is a lifetime of authorization code. RFC 6749 section 4.1.2 recommends at most 10 minutes.
+const AuthorizationCodeTTL = time.Minute
+
+//CodeChallengeMethodS256 is the only supported PKCE code challenge method, see RFC 7636 section 4.2.
+const CodeChallengeMethodS256 = "S256"
+
+//authorizationCodeLength is a number of random bytes in generated authorization code.
+const authorizationCodeLength = 32
+
+//Limits of PKCE code verifier and S256 code challenge length, see RFC 7636 section 4.1.
+const (
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+//AuthorizationCode is an representation of OAuth 2.0 authorization code that will be stored in mongoDB.
+//Codes are removed by mongoDB TTL index on expires_at.
+type AuthorizationCode struct {
+	//Hash is a SHA-256 hash of the code, the code itself is never stored.
+	Hash     string `bson:"_id"`
+	ClientID string `bson:"client_id"`
+	UserID   UserID `bson:"user_id"`
+	//RedirectURI is redirect_uri parameter of authorization request, it is empty if parameter was omitted.
+	RedirectURI   string   `bson:"redirect_uri"`
+	CodeChallenge string   `bson:"code_challenge"`
+	Scopes        []string `bson:"scopes"`
+	//Nonce is an OpenID Connect nonce parameter of authorization request.
+	Nonce string `bson:"nonce,omitempty"`
+	//AuthTime is a time user authenticated at to obtain the code.
+	AuthTime  time.Time `bson:"auth_time"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+//GenerateAuthorizationCode generates a new random authorization code.
+func GenerateAuthorizationCode() (string, error) {
+	code := make([]byte, authorizationCodeLength)
+	if _, err := rand.Read(code); err != nil {
+		log.Println(err.Error())
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+//HashAuthorizationCode returns hash under which authorization code is stored.
+func HashAuthorizationCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+//ValidateCodeChallenge checks PKCE code challenge and it`s method. Only S256 method is accepted.
+func ValidateCodeChallenge(challenge, method string) error {
+	if challenge == "" {
+		return &ArgumentError{Message: "Code challenge is required"}
+	}
+	if method != CodeChallengeMethodS256 {
+		return &ArgumentError{Message: "Code challenge method must be S256"}
+	}
+	//S256 challenge is base64url encoded SHA-256 hash without padding.
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	if err != nil || len(decoded) != sha256.Size {
+		return &ArgumentError{Message: "Code challenge is not valid"}
+	}
+	return nil
+}
+
+//VerifyCodeVerifier checks PKCE code verifier against code challenge of authorization code.
+func (c *AuthorizationCode) VerifyCodeVerifier(verifier string) error {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return &ArgumentError{Message: "Code verifier must be 43 to 128 characters long"}
+	}
+	for _, r := range verifier {
+		if !isUnreserved(r) {
+			return &ArgumentError{Message: "Code verifier contains not allowed characters"}
+		}
+	}
+	hash := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(hash[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) != 1 {
+		return ErrInvalidGrant
+	}
+	return nil
+}
+
+//isUnreserved reports whether r is an unreserved URI character, see RFC 3986 section 2.3.
+func isUnreserved(r rune) bool {
+	return 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' ||
+		r == '-' || r == '.' || r == '_' || r == '~'
+}
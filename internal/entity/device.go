@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+//GrantDeviceCode is a grant type of device authorization grant, see RFC 8628 section 3.4.
+const GrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+//Device authorization parameters, see RFC 8628 section 3.2 and 3.5.
+const (
+	DeviceCodeTTL = 10 * time.Minute
+	//DevicePollInterval is a minimal polling interval in seconds.
+	DevicePollInterval = 5
+	//DeviceSlowDown is a number of seconds polling interval is increased by after too frequent polling.
+	DeviceSlowDown = 5
+)
+
+//Statuses of device code.
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+//userCodeAlphabet consists of consonants only to avoid ambiguous characters and words, see RFC 8628 section 6.1.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+//userCodeLength is a number of characters in user code without separator.
+const userCodeLength = 8
+
+//DeviceCode is an representation of OAuth 2.0 device authorization request that will be stored in mongoDB.
+//Codes are removed by mongoDB TTL index on expires_at.
+type DeviceCode struct {
+	//Hash is a SHA-256 hash of device code, UserCodeHash is the one of normalized user code. Codes themselves are never stored.
+	Hash         string   `bson:"_id"`
+	UserCodeHash string   `bson:"user_code_hash"`
+	ClientID     string   `bson:"client_id"`
+	Scopes       []string `bson:"scopes"`
+	Status       string   `bson:"status"`
+	//UserID is an id of the user who approved or denied the request.
+	UserID UserID `bson:"user_id,omitempty"`
+	//Interval is a polling interval in seconds.
+	Interval     int64     `bson:"interval"`
+	LastPolledAt time.Time `bson:"last_polled_at"`
+	ExpiresAt    time.Time `bson:"expires_at"`
+}
+
+//GenerateDeviceCode generates a new random device code.
+func GenerateDeviceCode() (string, error) {
+	return GenerateAuthorizationCode()
+}
+
+//GenerateUserCode generates a new random user code formatted as XXXX-XXXX.
+func GenerateUserCode() (string, error) {
+	code := make([]byte, 0, userCodeLength+1)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, userCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+//HashDeviceCode returns hash under which device code is stored.
+func HashDeviceCode(code string) string {
+	return HashAuthorizationCode(code)
+}
+
+//HashUserCode returns hash under which user code is stored.
+//User code is normalized first, so it may be entered in any case with or without separators.
+func HashUserCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+//PolledTooSoon reports whether device polls more frequently than it`s interval allows.
+func (c *DeviceCode) PolledTooSoon(now time.Time) bool {
+	return !c.LastPolledAt.IsZero() && now.Before(c.LastPolledAt.Add(time.Duration(c.Interval)*time.Second))
+}
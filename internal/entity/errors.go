@@ -0,0 +1,73 @@
+package entity
+
+import "errors"
+
+//Domain errors returned by token issuance, parsing and storage.
+//Callers should compare against them with errors.Is, since implementations wrap them with details.
+var (
+	//ErrInvalidArgument is returned when a required argument is missing or malformed.
+	ErrInvalidArgument = errors.New("Invalid argument")
+	//ErrMalformedToken is returned when a token can not be decoded or parsed at all.
+	ErrMalformedToken = errors.New("Token is malformed")
+	//ErrInvalidSignature is returned when a token signature does not match the signing secret.
+	ErrInvalidSignature = errors.New("Token signature is invalid")
+	//ErrInvalidToken is returned when a token is well formed and signed but its claims are not acceptable.
+	ErrInvalidToken = errors.New("Token is not valid")
+	//ErrTokenExpired is returned when a token is past its expiration time.
+	ErrTokenExpired = errors.New("Token is expired")
+	//ErrTokenMismatch is returned when an access token was not issued together with the presented refresh token.
+	ErrTokenMismatch = errors.New("Access token does not belong to refresh token")
+	//ErrTokenNotFound is returned when there is no such refresh token in storage.
+	ErrTokenNotFound = errors.New("There is no such refresh token")
+	//ErrTokenUsed is returned when a refresh token has already been used for a refresh operation.
+	ErrTokenUsed = errors.New("Refresh token has already been used")
+	//ErrUserNotFound is returned when there is no user account with given id.
+	ErrUserNotFound = errors.New("There is no such user")
+	//ErrUserExists is returned when user with the same id is already registered.
+	ErrUserExists = errors.New("User already exists")
+	//ErrSessionLimit is returned when a new session would exceed the limit of active sessions.
+	ErrSessionLimit = errors.New("Too many active sessions")
+	//ErrSessionNotFound is returned when the user has no session with given id.
+	ErrSessionNotFound = errors.New("There is no such session")
+	//ErrClientNotFound is returned when there is no such OAuth 2.0 client.
+	ErrClientNotFound = errors.New("There is no such client")
+	//ErrCodeNotFound is returned when authorization or device code does not exist, has expired or was already redeemed.
+	ErrCodeNotFound = errors.New("There is no such code")
+	//ErrCodeExpired is returned when device code is past its expiration time.
+	ErrCodeExpired = errors.New("Code is expired")
+	//ErrCodeUsed is returned when user already approved or denied device authorization request.
+	ErrCodeUsed = errors.New("Code has already been used")
+	//ErrAuthorizationPending is returned when user has not yet approved device authorization request.
+	ErrAuthorizationPending = errors.New("Authorization is pending")
+	//ErrSlowDown is returned when device polls more frequently than allowed.
+	ErrSlowDown = errors.New("Polling is too frequent")
+	//ErrAccessDenied is returned when user denied device authorization request.
+	ErrAccessDenied = errors.New("User denied the request")
+	//ErrInvalidGrant is returned when authorization grant does not match the request, e.g. PKCE code verifier is wrong.
+	ErrInvalidGrant = errors.New("Authorization grant is not valid")
+	//ErrInvalidScope is returned when requested scope is not allowed for the client.
+	ErrInvalidScope = errors.New("Requested scope is not allowed")
+	//ErrUnauthenticated is returned when caller credentials are missing or not valid.
+	ErrUnauthenticated = errors.New("Caller is not authenticated")
+	//ErrForbidden is returned when authenticated caller is not allowed to perform the operation.
+	ErrForbidden = errors.New("Operation is not allowed for the caller")
+	//ErrOverloaded is returned when the service has too much work queued to complete the operation in time.
+	ErrOverloaded = errors.New("Service is overloaded")
+	//ErrStorageUnavailable is returned when storage could not be reached or failed to complete an operation.
+	ErrStorageUnavailable = errors.New("Storage is unavailable")
+)
+
+//ArgumentError describes missing or malformed argument in a client safe way.
+//It matches ErrInvalidArgument with errors.Is.
+type ArgumentError struct {
+	Message string
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Message
+}
+
+//Is reports whether target is ErrInvalidArgument.
+func (e *ArgumentError) Is(target error) bool {
+	return target == ErrInvalidArgument
+}
@@ -0,0 +1,31 @@
+package entity
+
+//GrantTokenExchange is a grant type of OAuth 2.0 token exchange, see RFC 8693 section 2.1.
+const GrantTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+//TokenTypeAccessToken is a token type identifier of access tokens of this service, see RFC 8693 section 3.
+//It is the only token type which may be exchanged or issued by token exchange.
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+//Actor is an act claim of exchanged access token, see RFC 8693 section 4.1.
+//Sub identifies the party acting on behalf of the subject of the token, nested Act is the previous actor of the chain.
+type Actor struct {
+	Sub string `json:"sub"`
+	Act *Actor `json:"act,omitempty"`
+}
+
+//Chain returns a copy of the chain of actors starting with a, which is followed by prior actors.
+func (a *Actor) Chain(prior *Actor) *Actor {
+	if a == nil {
+		return prior
+	}
+	return &Actor{Sub: a.Sub, Act: a.Act.Chain(prior)}
+}
+
+//Subject returns user id of the token or client id if the token was issued to the client itself.
+func (c *CustomClaimsAcessToken) Subject() string {
+	if c.User_id != "" {
+		return c.User_id
+	}
+	return c.Client_id
+}
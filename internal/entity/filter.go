@@ -0,0 +1,54 @@
+package entity
+
+import "time"
+
+//Statuses of refresh tokens.
+const (
+	//TokenStatusActive tokens are neither used nor expired.
+	TokenStatusActive = "active"
+	//TokenStatusUsed tokens were exchanged for a new pair.
+	TokenStatusUsed = "used"
+	//TokenStatusExpired tokens are unused and past their expiration time.
+	TokenStatusExpired = "expired"
+)
+
+//TokenFilter selects refresh tokens, zero fields match any token.
+type TokenFilter struct {
+	UserID   UserID
+	ClientID string
+	//SessionID selects the family of refresh tokens obtained by rotation.
+	SessionID string
+	//IssuedAfter and IssuedBefore bound issuance time of the token. Tokens issued before issuance time was recorded
+	//match IssuedBefore, so revoking everything issued before a breach does not leave them behind.
+	IssuedAfter  time.Time
+	IssuedBefore time.Time
+	//Status is one of TokenStatus* statuses, it is evaluated at Now.
+	Status string
+	Now    time.Time
+}
+
+//Validate checks that status of the filter is known.
+func (f *TokenFilter) Validate() error {
+	switch f.Status {
+	case "", TokenStatusActive, TokenStatusUsed, TokenStatusExpired:
+		return nil
+	}
+	return &ArgumentError{Message: "Status must be active, used or expired"}
+}
+
+//IsEmpty reports whether the filter matches every token.
+func (f *TokenFilter) IsEmpty() bool {
+	return f.UserID == "" && f.ClientID == "" && f.SessionID == "" && f.IssuedAfter.IsZero() && f.IssuedBefore.IsZero() && f.Status == ""
+}
+
+//Status returns status of the token at given time.
+func (t *RefreshToken) Status(now time.Time) string {
+	switch {
+	case t.Used:
+		return TokenStatusUsed
+	case t.ExpiresAt <= now.Unix():
+		return TokenStatusExpired
+	default:
+		return TokenStatusActive
+	}
+}
@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+//IDTokenTTL is a lifetime of OpenID Connect ID token.
+const IDTokenTTL = time.Hour
+
+//ScopeOpenID is a scope requesting OpenID Connect ID token. Every client may request it.
+const ScopeOpenID = "openid"
+
+//signingKeyBits is a size of generated RSA signing keys.
+const signingKeyBits = 2048
+
+//SigningKey is an RSA key ID tokens are signed with. Public part of it is published as JWK.
+type SigningKey struct {
+	//ID is a key id put into kid header of tokens.
+	ID      string
+	private *rsa.PrivateKey
+}
+
+//ParseSigningKey parses PEM encoded RSA private key.
+func ParseSigningKey(pem string) (*SigningKey, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pem))
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(key)
+}
+
+//GenerateSigningKey generates a new RSA signing key.
+func GenerateSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	return newSigningKey(key)
+}
+
+func newSigningKey(key *rsa.PrivateKey) (*SigningKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	//Key id is derived from public key, so it stays the same while the key does.
+	hash := sha256.Sum256(der)
+	return &SigningKey{
+		ID:      base64.RawURLEncoding.EncodeToString(hash[:16]),
+		private: key,
+	}, nil
+}
+
+//Algorithm returns JWS algorithm of the key.
+func (k *SigningKey) Algorithm() string {
+	return jwt.SigningMethodRS256.Alg()
+}
+
+//PublicModulus returns base64url encoded modulus of public key, see RFC 7518 section 6.3.1.
+func (k *SigningKey) PublicModulus() string {
+	return base64.RawURLEncoding.EncodeToString(k.private.PublicKey.N.Bytes())
+}
+
+//PublicExponent returns base64url encoded public exponent of the key.
+func (k *SigningKey) PublicExponent() string {
+	return base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.private.PublicKey.E)).Bytes())
+}
+
+//IDTokenParams describes OpenID Connect ID token to be issued together with a pair of tokens.
+type IDTokenParams struct {
+	Issuer string
+	//Nonce is a nonce parameter of authentication request, it is omitted if empty.
+	Nonce string
+	//AuthTime is a time user authenticated at.
+	AuthTime time.Time
+	Key      *SigningKey
+}
+
+//IDTokenClaims are claims of OpenID Connect ID token, see OpenID Connect Core section 2.
+type IDTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time"`
+	//AtHash is a hash of access token issued together with ID token.
+	AtHash string `json:"at_hash"`
+	jwt.StandardClaims
+}
+
+//createIDToken creates a new ID token for the user and client of params bound to access token.
+func createIDToken(params TokenParams, accessToken string) (string, error) {
+	idToken := params.IDToken
+	if idToken.Key == nil {
+		return "", errors.New("ID token signing key is not configured")
+	}
+	claims := IDTokenClaims{
+		Nonce:    idToken.Nonce,
+		AuthTime: idToken.AuthTime.Unix(),
+		AtHash:   accessTokenHash(accessToken),
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    idToken.Issuer,
+			Subject:   params.UserID.String(),
+			Audience:  params.ClientID,
+			IssuedAt:  params.IssuedAt.Unix(),
+			ExpiresAt: params.IssuedAt.Add(IDTokenTTL).Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idToken.Key.ID
+	return token.SignedString(idToken.Key.private)
+}
+
+//accessTokenHash returns at_hash of access token: left half of it`s SHA-256 hash, see OpenID Connect Core section 3.1.3.6.
+func accessTokenHash(accessToken string) string {
+	hash := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2])
+}
@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+//Formats of issued refresh tokens.
+const (
+	//RefreshTokenJWT is a signed jwt handed out base64 encoded and stored as bcrypt hash.
+	RefreshTokenJWT = "jwt"
+	//RefreshTokenOpaque is opaquePrefix, an id of the token, a dot and 256 random bits, stored as HMAC-SHA256 digest.
+	RefreshTokenOpaque = "opaque"
+)
+
+//opaquePrefix starts every opaque refresh token. Neither jwt nor it`s base64 encoding may start with it.
+const opaquePrefix = "rt_"
+
+//opaqueSecretSize is a size of random part of opaque refresh token in bytes.
+const opaqueSecretSize = 32
+
+//digestPrefix marks digests of opaque refresh tokens, bcrypt hashes of jwt refresh tokens start with $2.
+const digestPrefix = "$hmac-sha256$"
+
+//newOpaqueRefreshToken generates opaque refresh token with given id.
+func newOpaqueRefreshToken(id string) (string, error) {
+	secret := make([]byte, opaqueSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return opaquePrefix + id + "." + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+//IsOpaqueRefreshToken reports whether refresh token is opaque.
+func IsOpaqueRefreshToken(token string) bool {
+	return strings.HasPrefix(token, opaquePrefix)
+}
+
+//OpaqueRefreshTokenID returns id of opaque refresh token the token is looked up by.
+func OpaqueRefreshTokenID(token string) (string, error) {
+	id := strings.TrimPrefix(token, opaquePrefix)
+	dot := strings.Index(id, ".")
+	if dot <= 0 {
+		return "", fmt.Errorf("%w: refresh token has no id", ErrMalformedToken)
+	}
+	return id[:dot], nil
+}
+
+//RefreshTokenDigest returns HMAC-SHA256 digest of opaque refresh token keyed with token secret.
+//Token is random, so unlike passwords it does not need slow hashing.
+func RefreshTokenDigest(token string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("TOKEN_SECRET")))
+	mac.Write([]byte(token))
+	return digestPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+//VerifyOpaque checks opaque refresh token against digest of stored token in constant time.
+//Tokens issued before not before watermark are not valid, the watermark is read within ctx.
+func (t *RefreshToken) VerifyOpaque(ctx context.Context, token string, now time.Time) error {
+	if !strings.HasPrefix(t.Token, digestPrefix) || !hmac.Equal([]byte(RefreshTokenDigest(token)), []byte(t.Token)) {
+		return fmt.Errorf("Refresh token is not valid: %w", ErrInvalidToken)
+	}
+	if t.ExpiresAt < now.Unix() {
+		return fmt.Errorf("Refresh token is not valid: %w", ErrTokenExpired)
+	}
+	if err := checkNotBefore(ctx, t.UserID.String(), t.RotatedAt); err != nil {
+		return fmt.Errorf("Refresh token is not valid: %w", err)
+	}
+	return nil
+}
+
+//Claims returns stored token in the form of claims of jwt refresh token, so both formats are handled alike.
+func (t *RefreshToken) Claims() *CustomClaimsRefreshToken {
+	return &CustomClaimsRefreshToken{
+		User_id:   t.UserID.String(),
+		UUID:      t.UUID,
+		Client_id: t.ClientID,
+		Scope:     FormatScope(t.Scopes),
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: t.ExpiresAt,
+			IssuedAt:  t.RotatedAt,
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//AnyRoute is a key of RateLimits applied to routes without own limit.
+const AnyRoute = "*"
+
+//RateLimit allows Requests requests per Period with bursts of up to Requests requests.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+//RateLimits are rate limits of routes keyed by method and route pattern, e.g. "POST /auth/login", or AnyRoute.
+type RateLimits map[string]RateLimit
+
+//ParseRateLimits parses semicolon separated list of route=requests/period entries,
+//e.g. "POST /auth/login=5/1m;*=60/1m".
+func ParseRateLimits(s string) (RateLimits, error) {
+	limits := RateLimits{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		eq := strings.LastIndex(entry, "=")
+		slash := strings.LastIndex(entry, "/")
+		if eq <= 0 || slash < eq {
+			return nil, fmt.Errorf("Rate limit %q must be route=requests/period", entry)
+		}
+		requests, err := strconv.Atoi(entry[eq+1 : slash])
+		if err != nil || requests <= 0 {
+			return nil, fmt.Errorf("Rate limit %q must allow a positive number of requests", entry)
+		}
+		period, err := time.ParseDuration(entry[slash+1:])
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("Rate limit %q must have a positive period", entry)
+		}
+		limits[strings.TrimSpace(entry[:eq])] = RateLimit{Requests: requests, Period: period}
+	}
+	return limits, nil
+}
+
+//For returns rate limit of the route or the one of AnyRoute. It reports false if the route is not limited.
+func (l RateLimits) For(route string) (RateLimit, bool) {
+	if limit, ok := l[route]; ok {
+		return limit, true
+	}
+	limit, ok := l[AnyRoute]
+	return limit, ok
+}
+
+//Bucket is a token bucket of rate limited key that will be stored in mongoDB.
+type Bucket struct {
+	Key       string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	//ExpiresAt is a time the bucket is full again, so it may be forgotten.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+//Peek refills the bucket according to the limit without taking a token from it.
+//It returns zero if a token may be taken and time until the next token otherwise.
+func (b *Bucket) Peek(limit RateLimit, now time.Time) time.Duration {
+	capacity := float64(limit.Requests)
+	perToken := limit.perToken()
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+float64(elapsed)/float64(perToken))
+	}
+	b.UpdatedAt = now
+	b.ExpiresAt = now.Add(time.Duration((capacity - b.Tokens) * float64(perToken)))
+	if b.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.Tokens) * float64(perToken)))
+}
+
+//Take refills the bucket according to the limit and takes one token from it.
+//It returns zero if the token was taken and time until the next token otherwise.
+func (b *Bucket) Take(limit RateLimit, now time.Time) time.Duration {
+	if wait := b.Peek(limit, now); wait > 0 {
+		return wait
+	}
+	b.Tokens--
+	b.ExpiresAt = b.ExpiresAt.Add(limit.perToken())
+	return 0
+}
+
+//perToken is time it takes to refill one token.
+func (l RateLimit) perToken() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
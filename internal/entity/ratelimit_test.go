@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		limits RateLimits
+		err    bool
+	}{
+		{"empty", "", RateLimits{}, false},
+		{"single route", "POST /auth/login=5/1m", RateLimits{"POST /auth/login": {5, time.Minute}}, false},
+		{"any route and spaces around entries", " POST /auth/login=5/1m ; *=60/1h ;", RateLimits{"POST /auth/login": {5, time.Minute}, AnyRoute: {60, time.Hour}}, false},
+		{"route with equals sign", "GET /a=b=1/1s", RateLimits{"GET /a=b": {1, time.Second}}, false},
+		{"later entry wins", "*=1/1s;*=2/1s", RateLimits{AnyRoute: {2, time.Second}}, false},
+		{"no route", "=5/1m", nil, true},
+		{"no period", "*=5", nil, true},
+		{"slash in route only", "GET /a=5", nil, true},
+		{"zero requests", "*=0/1m", nil, true},
+		{"negative requests", "*=-1/1m", nil, true},
+		{"not a number", "*=five/1m", nil, true},
+		{"bad period", "*=5/minute", nil, true},
+		{"zero period", "*=5/0s", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, err := ParseRateLimits(tt.s)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(limits, tt.limits) {
+				t.Errorf("got %v, want %v", limits, tt.limits)
+			}
+		})
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	limit := RateLimit{Requests: 2, Period: 10 * time.Second}
+	start := time.Unix(1600000000, 0)
+	//Takes are done in order on the same bucket, at seconds from start.
+	tests := []struct {
+		name string
+		at   time.Duration
+		wait time.Duration
+	}{
+		{"full bucket", 0, 0},
+		{"burst", 0, 0},
+		{"empty bucket", 0, 5 * time.Second},
+		{"partly refilled", 3 * time.Second, 2 * time.Second},
+		{"refilled token", 5 * time.Second, 0},
+		{"clock going back does not refill", 4 * time.Second, 5 * time.Second},
+		{"refill is capped", time.Hour, 0},
+		{"capacity after long pause", time.Hour, 0},
+		{"empty again", time.Hour, 5 * time.Second},
+	}
+	bucket := &Bucket{Key: "key"}
+	for _, tt := range tests {
+		now := start.Add(tt.at)
+		if wait := bucket.Take(limit, now); wait != tt.wait {
+			t.Fatalf("%s: got wait %s, want %s", tt.name, wait, tt.wait)
+		}
+		if want := now.Add(time.Duration((2 - bucket.Tokens) * float64(5*time.Second))); !bucket.ExpiresAt.Equal(want) {
+			t.Errorf("%s: got bucket full at %s, want %s", tt.name, bucket.ExpiresAt, want)
+		}
+	}
+}
+
+func TestBucketPeek(t *testing.T) {
+	limit := RateLimit{Requests: 1, Period: time.Second}
+	now := time.Unix(1600000000, 0)
+	bucket := &Bucket{Key: "key"}
+	for i := 0; i < 3; i++ {
+		if wait := bucket.Peek(limit, now); wait != 0 {
+			t.Fatalf("got wait %s of peek %d, want none", wait, i)
+		}
+	}
+	if wait := bucket.Take(limit, now); wait != 0 {
+		t.Fatalf("got wait %s, peeking must not take tokens", wait)
+	}
+	if wait := bucket.Peek(limit, now); wait != time.Second {
+		t.Errorf("got wait %s of empty bucket, want 1s", wait)
+	}
+}
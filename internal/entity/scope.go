@@ -0,0 +1,41 @@
+package entity
+
+import "strings"
+
+//ParseScope splits space separated scope parameter into scopes, see RFC 6749 section 3.3.
+//Duplicates are removed, nil is returned for empty parameter.
+func ParseScope(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+//FormatScope joins scopes into space separated scope parameter.
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+//IntersectScopes returns scopes of requested which are also allowed, in the order they were requested.
+func IntersectScopes(requested, allowed []string) []string {
+	var scopes []string
+	for _, s := range requested {
+		if contains(allowed, s) && !contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+//IsScopeSubset reports whether every scope of scopes is also in of.
+func IsScopeSubset(scopes, of []string) bool {
+	for _, s := range scopes {
+		if !contains(of, s) {
+			return false
+		}
+	}
+	return true
+}
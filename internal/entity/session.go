@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//Policies applied when a new session would exceed SessionLimit.
+const (
+	//SessionLimitReject rejects issuing tokens for a new session.
+	SessionLimitReject = "reject"
+	//SessionLimitEvict deletes least recently rotated sessions to make room for a new one.
+	SessionLimitEvict = "evict"
+)
+
+//SessionLimit limits numbers of active sessions, i.e. unused and unexpired refresh tokens.
+type SessionLimit struct {
+	//PerUser limits sessions of the user, PerClient limits sessions of the user with one OAuth 2.0 client.
+	//Zero means no limit.
+	PerUser   int
+	PerClient int
+	Policy    string
+}
+
+//ParseSessionLimit parses session limits and policy of configuration. Empty limits mean no limit,
+//empty policy is SessionLimitReject.
+func ParseSessionLimit(perUser, perClient, policy string) (SessionLimit, error) {
+	limit := SessionLimit{Policy: policy}
+	for _, l := range []struct {
+		value string
+		limit *int
+	}{{perUser, &limit.PerUser}, {perClient, &limit.PerClient}} {
+		if l.value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(l.value)
+		if err != nil || n < 0 {
+			return SessionLimit{}, fmt.Errorf("Session limit %q must be a non negative number", l.value)
+		}
+		*l.limit = n
+	}
+	switch limit.Policy {
+	case "":
+		limit.Policy = SessionLimitReject
+	case SessionLimitReject, SessionLimitEvict:
+	default:
+		return SessionLimit{}, fmt.Errorf("Session limit policy %q is not supported", policy)
+	}
+	return limit, nil
+}
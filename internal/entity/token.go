@@ -1,11 +1,13 @@
 package entity
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -13,25 +15,46 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+//Lifetimes of issued tokens.
+const (
+	AccessTokenTTL  = time.Hour * 24 * 7
+	RefreshTokenTTL = time.Hour * 24 * 7
+)
+
 //AccessToken is an representation of jwt access token.
 type AccessToken struct {
 	Token     string
 	ExpiresAt int64
 }
 
-//RefreshToken is an representation of jwt refresh token that will be stored in mongoDB.
+//RefreshToken is an representation of refresh token that will be stored in mongoDB.
+//Token is a bcrypt hash of jwt refresh token or a digest of opaque refresh token.
 type RefreshToken struct {
-	UUID      string `bson:"_id"`
-	UserID    string `bson:"user_id"`
-	Token     string `bson:"token"`
-	ExpiresAt int64  `bson:"expires_at"`
-	Used      bool   `bson:"used"`
+	UUID   string `bson:"_id"`
+	UserID UserID `bson:"user_id"`
+	//ClientID is an id of OAuth 2.0 client the token was issued to. It is empty for tokens issued directly to users.
+	ClientID string `bson:"client_id,omitempty"`
+	//Scopes are scopes granted to the token, rotation may narrow but never widen them.
+	Scopes    []string `bson:"scopes,omitempty"`
+	Token     string   `bson:"token"`
+	ExpiresAt int64    `bson:"expires_at"`
+	Used      bool     `bson:"used"`
+	//SessionID identifies the family of refresh tokens obtained by rotation, it is UUID of the first token of the family.
+	//CreatedAt is a creation time of the family and RotatedAt is an issuance time of the token, both in unix seconds.
+	SessionID string `bson:"session_id,omitempty"`
+	CreatedAt int64  `bson:"created_at,omitempty"`
+	RotatedAt int64  `bson:"rotated_at,omitempty"`
+	//UserAgent and IP describe the device the token was issued to.
+	UserAgent string `bson:"user_agent,omitempty"`
+	IP        string `bson:"ip,omitempty"`
 }
 
 //TokenPair is an representation of access and refresh token pair.
 type TokenPair struct {
 	AccessToken  AccessToken
 	RefreshToken RefreshToken
+	//IDToken is an OpenID Connect ID token, it is only issued if requested by TokenParams.
+	IDToken string
 }
 
 //CustomClaimsAcessToken is a set of additional claims for jwt access token.
@@ -39,59 +62,190 @@ type CustomClaimsAcessToken struct {
 	User_id string
 	//This field helps to bind access token to refresh token.
 	Refresh_uuid string
+	Client_id    string
+	//Scope is a space separated list of granted scopes.
+	Scope string `json:"scope,omitempty"`
+	//Roles are roles of the user at the time of issuance.
+	Roles []string `json:"roles,omitempty"`
+	//Act is a chain of parties acting on behalf of the user, it is only set on exchanged tokens.
+	Act *Actor `json:"act,omitempty"`
 	jwt.StandardClaims
 }
 
 //CustomClaimsRefreshToken is a Set of additional claims for jwt refresh token.
 type CustomClaimsRefreshToken struct {
-	User_id string
-	UUID    string
+	User_id   string
+	UUID      string
+	Client_id string
+	//Scope is a space separated list of scopes granted to the refresh token.
+	Scope string `json:"scope,omitempty"`
 	jwt.StandardClaims
 }
 
+//TokenParams describes a pair of tokens to be issued.
+type TokenParams struct {
+	UserID UserID
+	//ClientID is an id of OAuth 2.0 client tokens are issued to, it is empty if tokens are issued directly to the user.
+	ClientID         string
+	RefreshTokenUUID string
+	//RefreshTokenFormat is either RefreshTokenJWT, the default, or RefreshTokenOpaque.
+	RefreshTokenFormat string
+	IssuedAt           time.Time
+	//Scopes are granted scopes and Roles are roles of the user, both are embedded into access token.
+	Scopes []string
+	Roles  []string
+	//AccessTokenTTL and RefreshTokenTTL override default lifetimes of tokens if they are not zero.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	//IDToken requests OpenID Connect ID token to be issued together with the pair if it is not nil.
+	IDToken *IDTokenParams
+	//Audience and Actor are only embedded into access tokens created by NewAccessToken.
+	Audience string
+	Actor    *Actor
+	//SessionID and SessionCreatedAt are kept across rotations of refresh token,
+	//a new session identified by RefreshTokenUUID is started if SessionID is empty.
+	SessionID        string
+	SessionCreatedAt time.Time
+	//UserAgent and IP describe the device the pair is issued to.
+	UserAgent string
+	IP        string
+}
+
+//accessTokenExpiresAt returns expiration time of access token.
+func (p TokenParams) accessTokenExpiresAt() int64 {
+	ttl := AccessTokenTTL
+	if p.AccessTokenTTL != 0 {
+		ttl = p.AccessTokenTTL
+	}
+	return p.IssuedAt.Add(ttl).Unix()
+}
+
+//session returns id and creation time of the session refresh token belongs to.
+func (p TokenParams) session() (string, int64) {
+	if p.SessionID == "" {
+		return p.RefreshTokenUUID, p.IssuedAt.Unix()
+	}
+	return p.SessionID, p.SessionCreatedAt.Unix()
+}
+
+//refreshTokenExpiresAt returns expiration time of refresh token.
+func (p TokenParams) refreshTokenExpiresAt() int64 {
+	ttl := RefreshTokenTTL
+	if p.RefreshTokenTTL != 0 {
+		ttl = p.RefreshTokenTTL
+	}
+	return p.IssuedAt.Add(ttl).Unix()
+}
+
 //CreateTokenPair creates a new pair of access and refresh tokens.
-func CreateTokenPair(userID string) (*TokenPair, error) {
+func CreateTokenPair(userID UserID) (*TokenPair, error) {
+	return NewTokenPair(TokenParams{
+		UserID:           userID,
+		RefreshTokenUUID: uuid.New().String(),
+		IssuedAt:         time.Now(),
+	})
+}
+
+//NewTokenPair creates a new pair of access and refresh tokens described by params.
+func NewTokenPair(params TokenParams) (*TokenPair, error) {
 
-	refreshTokenExp := time.Now().Add(time.Hour * 24 * 7).Unix()
-	refreshTokenUUID := uuid.New().String()
-	refreshToken, err := createRefreshToken(userID, refreshTokenUUID, refreshTokenExp)
+	refreshTokenExp := params.refreshTokenExpiresAt()
+	var refreshToken string
+	var err error
+	if params.RefreshTokenFormat == RefreshTokenOpaque {
+		refreshToken, err = newOpaqueRefreshToken(params.RefreshTokenUUID)
+	} else {
+		refreshToken, err = createRefreshToken(CustomClaimsRefreshToken{
+			User_id:   params.UserID.String(),
+			UUID:      params.RefreshTokenUUID,
+			Client_id: params.ClientID,
+			Scope:     FormatScope(params.Scopes),
+			StandardClaims: jwt.StandardClaims{
+				ExpiresAt: refreshTokenExp,
+				IssuedAt:  params.IssuedAt.Unix(),
+			},
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	accessTokenExp := time.Now().Add(time.Hour * 24 * 7).Unix()
-	accessToken, err := createAccessToken(userID, refreshTokenUUID, accessTokenExp)
+	accessTokenExp := params.accessTokenExpiresAt()
+	accessToken, err := createAccessToken(CustomClaimsAcessToken{
+		User_id:      params.UserID.String(),
+		Refresh_uuid: params.RefreshTokenUUID,
+		Client_id:    params.ClientID,
+		Scope:        FormatScope(params.Scopes),
+		Roles:        params.Roles,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: accessTokenExp,
+			IssuedAt:  params.IssuedAt.Unix(),
+		},
+	})
 	if err != nil {
 		log.Println(err.Error())
 		return nil, err
 	}
 
+	sessionID, sessionCreatedAt := params.session()
 	tokens := &TokenPair{
 		AccessToken: AccessToken{
 			Token:     accessToken,
 			ExpiresAt: accessTokenExp,
 		},
 		RefreshToken: RefreshToken{
-			UserID:    userID,
-			UUID:      refreshTokenUUID,
+			UserID:    params.UserID,
+			ClientID:  params.ClientID,
+			Scopes:    params.Scopes,
+			UUID:      params.RefreshTokenUUID,
 			Token:     refreshToken,
 			ExpiresAt: refreshTokenExp,
 			Used:      false,
+			SessionID: sessionID,
+			CreatedAt: sessionCreatedAt,
+			RotatedAt: params.IssuedAt.Unix(),
+			UserAgent: params.UserAgent,
+			IP:        params.IP,
 		},
 	}
+	if params.IDToken != nil {
+		tokens.IDToken, err = createIDToken(params, accessToken)
+		if err != nil {
+			log.Println(err.Error())
+			return nil, err
+		}
+	}
 	return tokens, nil
 }
 
-//createAccessToken creates a new jwt refresh token.
-func createAccessToken(userID string, refreshUUID string, expires int64) (string, error) {
-	claims := CustomClaimsAcessToken{
-		User_id:      userID,
-		Refresh_uuid: refreshUUID,
+//NewAccessToken creates a new access token described by params that is not bound to any refresh token.
+//Refresh token fields of params are ignored.
+func NewAccessToken(params TokenParams) (*AccessToken, error) {
+	accessTokenExp := params.accessTokenExpiresAt()
+	accessToken, err := createAccessToken(CustomClaimsAcessToken{
+		User_id:   params.UserID.String(),
+		Client_id: params.ClientID,
+		Scope:     FormatScope(params.Scopes),
+		Roles:     params.Roles,
+		Act:       params.Actor,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expires,
+			Audience:  params.Audience,
+			ExpiresAt: accessTokenExp,
+			IssuedAt:  params.IssuedAt.Unix(),
 		},
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
 	}
+	return &AccessToken{
+		Token:     accessToken,
+		ExpiresAt: accessTokenExp,
+	}, nil
+}
 
+//createAccessToken creates a new jwt access token.
+func createAccessToken(claims CustomClaimsAcessToken) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 	signedToken, err := token.SignedString([]byte(os.Getenv("TOKEN_SECRET")))
 	if err != nil {
@@ -101,15 +255,7 @@ func createAccessToken(userID string, refreshUUID string, expires int64) (string
 }
 
 //createRefreshToken creates a new jwt refresh token.
-func createRefreshToken(userID, UUID string, expires int64) (string, error) {
-	claims := CustomClaimsRefreshToken{
-		User_id: userID,
-		UUID:    UUID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expires,
-		},
-	}
-
+func createRefreshToken(claims CustomClaimsRefreshToken) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 	signedToken, err := token.SignedString([]byte(os.Getenv("TOKEN_SECRET")))
 	if err != nil {
@@ -119,44 +265,52 @@ func createRefreshToken(userID, UUID string, expires int64) (string, error) {
 }
 
 //ParseRefreshToken checks validity of refresh token and returns it`s claims.
-func ParseRefreshToken(tokenString string) (*CustomClaimsRefreshToken, error) {
+//Tokens issued before not before watermark are not valid, the watermark is read within ctx.
+func ParseRefreshToken(ctx context.Context, tokenString string) (*CustomClaimsRefreshToken, error) {
 	claims := &CustomClaimsRefreshToken{}
 	token, err := ParseJWTToken(tokenString, claims)
 	if err != nil {
-		return nil, fmt.Errorf("Refresh token is not valid. %s", err.Error())
+		return nil, fmt.Errorf("Refresh token is not valid: %w", err)
 	}
 
 	claims, ok := token.Claims.(*CustomClaimsRefreshToken)
 	if !ok || !token.Valid {
-		return nil, errors.New("Refresh token is not valid")
+		return nil, fmt.Errorf("Refresh token is not valid: %w", ErrInvalidToken)
 	}
 	if claims.ExpiresAt < time.Now().UTC().Unix() {
-		return nil, errors.New("Refresh token is expired")
+		return nil, fmt.Errorf("Refresh token is not valid: %w", ErrTokenExpired)
+	}
+	if err := checkNotBefore(ctx, claims.User_id, claims.IssuedAt); err != nil {
+		return nil, fmt.Errorf("Refresh token is not valid: %w", err)
 	}
 	return claims, nil
 }
 
 //ParseAccessToken checks validity of access token and returns it`s claims.
-func ParseAccessToken(tokenString string) (*CustomClaimsAcessToken, error) {
+//Tokens issued before not before watermark are not valid, the watermark is read within ctx.
+func ParseAccessToken(ctx context.Context, tokenString string) (*CustomClaimsAcessToken, error) {
 	claims := &CustomClaimsAcessToken{}
 	token, err := ParseJWTToken(tokenString, claims)
 	if err != nil {
-		return nil, fmt.Errorf("Access token is not valid. %s", err.Error())
+		return nil, fmt.Errorf("Access token is not valid: %w", err)
 	}
 
 	claims, ok := token.Claims.(*CustomClaimsAcessToken)
-	if err != nil {
-		return nil, fmt.Errorf("Refresh token is not valid: %s", err.Error())
-	}
-
 	if !ok || !token.Valid {
-		return nil, errors.New("Access token is not valid")
+		return nil, fmt.Errorf("Access token is not valid: %w", ErrInvalidToken)
+	}
+	if err := checkNotBefore(ctx, claims.User_id, claims.IssuedAt); err != nil {
+		return nil, fmt.Errorf("Access token is not valid: %w", err)
 	}
 	return claims, nil
 }
 
 //ParseJWTToken parses token string into jwt token.
+//Validation failures are reported as ErrMalformedToken, ErrInvalidSignature, ErrTokenExpired or ErrInvalidToken.
 func ParseJWTToken(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	if len(tokenString) > MaxTokenLength {
+		return nil, fmt.Errorf("%w: token is too long", ErrMalformedToken)
+	}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
@@ -164,14 +318,36 @@ func ParseJWTToken(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
 		return []byte(os.Getenv("TOKEN_SECRET")), nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Token is not valid: %s", err.Error())
+		return nil, fmt.Errorf("%w: %s", jwtValidationError(err), err.Error())
 	}
 	return token, nil
 }
 
-//GenerateHash generates bcrypt hash.
-func GenerateHash(s string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(s), 12)
+//jwtValidationError maps jwt-go validation errors onto domain errors.
+func jwtValidationError(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return ErrInvalidToken
+	}
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return ErrMalformedToken
+	case ve.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable) != 0:
+		return ErrInvalidSignature
+	case ve.Errors&jwt.ValidationErrorExpired != 0:
+		return ErrTokenExpired
+	default:
+		return ErrInvalidToken
+	}
+}
+
+//DefaultHashCost is a bcrypt cost of hashes unless configured otherwise.
+const DefaultHashCost = 12
+
+//GenerateHash generates bcrypt hash with given cost.
+//It takes hundreds of milliseconds of CPU, so it should be run on hashing.Pool rather than on request goroutine.
+func GenerateHash(s string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(s), cost)
 	if err != nil {
 		log.Println(err.Error())
 		return "", err
@@ -180,18 +356,28 @@ func GenerateHash(s string) (string, error) {
 	return res, nil
 }
 
-//EncodeToken64 encodes into base64 encoding.
+//MaxTokenLength is the longest token accepted for decoding or parsing, longer input is rejected without being processed.
+const MaxTokenLength = 8 << 10
+
+//standardToURL converts standard base64 alphabet into URL-safe one.
+var standardToURL = strings.NewReplacer("+", "-", "/", "_")
+
+//EncodeToken64 encodes into URL-safe base64 encoding without padding, so token may be put into query strings and cookies as is.
 func EncodeToken64(token string) string {
-	refreshToken := base64.StdEncoding.EncodeToString([]byte(token))
+	refreshToken := base64.RawURLEncoding.EncodeToString([]byte(token))
 	return refreshToken
 }
 
-//DecodeToken64 decodes from base64 encoding.
+//DecodeToken64 decodes from base64 encoding. Both URL-safe and standard alphabets with or without padding are accepted,
+//tokens encoded by earlier versions of the service keep working.
 func DecodeToken64(token string) (string, error) {
-	refreshToken, err := base64.StdEncoding.DecodeString(token)
+	if len(token) > MaxTokenLength {
+		return "", fmt.Errorf("Token is too long: %w", ErrMalformedToken)
+	}
+	refreshToken, err := base64.RawURLEncoding.DecodeString(standardToURL.Replace(strings.TrimRight(token, "=")))
 	if err != nil {
 		log.Println(err.Error())
-		return "", errors.New("Error decoding access token")
+		return "", fmt.Errorf("Error decoding token: %w", ErrMalformedToken)
 	}
 
 	return string(refreshToken), nil
@@ -0,0 +1,296 @@
+package entity
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testSecret = "test token secret"
+
+const testUserID = "0b4f7d2e-9a3c-4e51-8f6d-2c7a1b9e0d34"
+
+func TestMain(m *testing.M) {
+	os.Setenv("TOKEN_SECRET", testSecret)
+	os.Exit(m.Run())
+}
+
+func sign(t testing.TB, method jwt.SigningMethod, claims jwt.Claims, key interface{}) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestDecodeToken64(t *testing.T) {
+	//Bytes encoding to both characters which differ between URL-safe and standard alphabets.
+	token := "\xfb\xff\xbf jwt"
+	tests := []struct {
+		name  string
+		token string
+		want  string
+		err   error
+	}{
+		{"url-safe", base64.RawURLEncoding.EncodeToString([]byte(token)), token, nil},
+		{"url-safe padded", base64.URLEncoding.EncodeToString([]byte(token)), token, nil},
+		{"standard", base64.RawStdEncoding.EncodeToString([]byte(token)), token, nil},
+		{"standard padded", base64.StdEncoding.EncodeToString([]byte(token)), token, nil},
+		{"encoded by EncodeToken64", EncodeToken64(token), token, nil},
+		{"empty", "", "", nil},
+		{"illegal character", "a.b*c", "", ErrMalformedToken},
+		{"truncated", "a", "", ErrMalformedToken},
+		{"padding inside", "YQ==YQ", "", ErrMalformedToken},
+		{"overlong", strings.Repeat("a", MaxTokenLength+4), "", ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeToken64(tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAccessToken(t *testing.T) {
+	now := time.Now().Unix()
+	claims := func(expiresAt int64) *CustomClaimsAcessToken {
+		return &CustomClaimsAcessToken{
+			User_id:        testUserID,
+			Refresh_uuid:   "refresh",
+			StandardClaims: jwt.StandardClaims{IssuedAt: now, ExpiresAt: expiresAt},
+		}
+	}
+	valid := sign(t, jwt.SigningMethodHS512, claims(now+60), []byte(testSecret))
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", valid, nil},
+		{"HS256", sign(t, jwt.SigningMethodHS256, claims(now+60), []byte(testSecret)), nil},
+		{"expired", sign(t, jwt.SigningMethodHS512, claims(now-60), []byte(testSecret)), ErrTokenExpired},
+		{"other secret", sign(t, jwt.SigningMethodHS512, claims(now+60), []byte("other secret")), ErrInvalidSignature},
+		{"alg none", sign(t, jwt.SigningMethodNone, claims(now+60), jwt.UnsafeAllowNoneSignatureType), ErrInvalidSignature},
+		{"tampered payload", tamper(valid), ErrInvalidSignature},
+		{"empty", "", ErrMalformedToken},
+		{"not a jwt", "token", ErrMalformedToken},
+		{"illegal base64", "a*.b*.c*", ErrMalformedToken},
+		{"base64 encoded", EncodeToken64(valid), ErrMalformedToken},
+		{"overlong", valid + strings.Repeat("a", MaxTokenLength), ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAccessToken(context.Background(), tt.token)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("got error %v", err)
+				}
+				if got.User_id != testUserID || got.Refresh_uuid != "refresh" {
+					t.Errorf("got claims %+v", got)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseRefreshToken(t *testing.T) {
+	now := time.Now().Unix()
+	claims := func(expiresAt int64) *CustomClaimsRefreshToken {
+		return &CustomClaimsRefreshToken{
+			User_id:        testUserID,
+			UUID:           "refresh",
+			StandardClaims: jwt.StandardClaims{IssuedAt: now, ExpiresAt: expiresAt},
+		}
+	}
+	valid := sign(t, jwt.SigningMethodHS512, claims(now+60), []byte(testSecret))
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", valid, nil},
+		{"expired", sign(t, jwt.SigningMethodHS512, claims(now-60), []byte(testSecret)), ErrTokenExpired},
+		//jwt-go accepts tokens without exp, but refresh tokens must expire.
+		{"without expiry", sign(t, jwt.SigningMethodHS512, claims(0), []byte(testSecret)), ErrTokenExpired},
+		{"other secret", sign(t, jwt.SigningMethodHS512, claims(now+60), []byte("other secret")), ErrInvalidSignature},
+		{"alg none", sign(t, jwt.SigningMethodNone, claims(now+60), jwt.UnsafeAllowNoneSignatureType), ErrInvalidSignature},
+		{"tampered payload", tamper(valid), ErrInvalidSignature},
+		{"empty", "", ErrMalformedToken},
+		{"two segments", "a.b", ErrMalformedToken},
+		{"padded segments", strings.Replace(valid, ".", "==.", 1), ErrMalformedToken},
+		{"overlong", valid + strings.Repeat("a", MaxTokenLength), ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRefreshToken(context.Background(), tt.token)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("got error %v", err)
+				}
+				if got.User_id != testUserID || got.UUID != "refresh" {
+					t.Errorf("got claims %+v", got)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+//tamper replaces user id in payload of jwt token keeping it`s signature.
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	payload = []byte(strings.Replace(string(payload), testUserID, "11111111-1111-1111-1111-111111111111", 1))
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+type watermarkFunc func(context.Context, UserID) (int64, error)
+
+func (f watermarkFunc) NotBefore(ctx context.Context, userID UserID) (int64, error) {
+	return f(ctx, userID)
+}
+
+type callerKey struct{}
+
+func TestParseTokenWatermark(t *testing.T) {
+	defer UseWatermarks(nil)
+	now := time.Now().Unix()
+	access := sign(t, jwt.SigningMethodHS512, &CustomClaimsAcessToken{
+		User_id:        testUserID,
+		StandardClaims: jwt.StandardClaims{IssuedAt: now, ExpiresAt: now + 60},
+	}, []byte(testSecret))
+	refresh := sign(t, jwt.SigningMethodHS512, &CustomClaimsRefreshToken{
+		User_id:        testUserID,
+		StandardClaims: jwt.StandardClaims{IssuedAt: now, ExpiresAt: now + 60},
+	}, []byte(testSecret))
+	tests := []struct {
+		name      string
+		notBefore int64
+		storage   error
+		err       error
+	}{
+		{"no watermark", 0, nil, nil},
+		{"issued after the watermark", now - 1, nil, nil},
+		{"issued in the second of the watermark", now, nil, ErrInvalidToken},
+		{"issued before the watermark", now + 1, nil, ErrInvalidToken},
+		{"storage unavailable", 0, ErrStorageUnavailable, ErrStorageUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UseWatermarks(watermarkFunc(func(ctx context.Context, userID UserID) (int64, error) {
+				if userID != testUserID {
+					t.Errorf("watermark of user %q is read, want %q", userID, testUserID)
+				}
+				if ctx.Value(callerKey{}) == nil {
+					t.Error("watermark is not read within context of the caller")
+				}
+				return tt.notBefore, tt.storage
+			}))
+			ctx := context.WithValue(context.Background(), callerKey{}, true)
+			_, err := ParseAccessToken(ctx, access)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("access token: got error %v, want %v", err, tt.err)
+			}
+			_, err = ParseRefreshToken(ctx, refresh)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("refresh token: got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+//tokenSeeds returns inputs the fuzz targets start from: valid tokens in every encoding together with padded, oversized and garbage ones.
+func tokenSeeds(token string) []string {
+	return []string{
+		token,
+		base64.StdEncoding.EncodeToString([]byte(token)),
+		base64.RawStdEncoding.EncodeToString([]byte(token)),
+		base64.URLEncoding.EncodeToString([]byte(token)),
+		EncodeToken64(token),
+		strings.Replace(token, ".", "==.", 1),
+		token + "==",
+		strings.Repeat("a", MaxTokenLength),
+		strings.Repeat("a", MaxTokenLength+1),
+		token + strings.Repeat("a", MaxTokenLength),
+		"",
+		"token",
+		"a.b.c",
+		"a*.b*.c*",
+		"YQ==YQ",
+		"\x00\xff\xfe",
+	}
+}
+
+func FuzzDecodeToken64(f *testing.F) {
+	for _, seed := range tokenSeeds("\xfb\xff\xbf jwt") {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		got, err := DecodeToken64(token)
+		if len(token) > MaxTokenLength && !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("token of %d bytes: got error %v, want %v", len(token), err, ErrMalformedToken)
+		}
+		if err != nil {
+			return
+		}
+		again, err := DecodeToken64(EncodeToken64(got))
+		if err != nil || again != got {
+			t.Errorf("%q does not survive encoding: got %q, %v", got, again, err)
+		}
+	})
+}
+
+func FuzzParseAccessToken(f *testing.F) {
+	now := time.Now().Unix()
+	valid := sign(f, jwt.SigningMethodHS512, &CustomClaimsAcessToken{
+		User_id:        testUserID,
+		StandardClaims: jwt.StandardClaims{IssuedAt: now, ExpiresAt: now + 3600},
+	}, []byte(testSecret))
+	for _, seed := range tokenSeeds(valid) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		_, err := ParseAccessToken(context.Background(), token)
+		if len(token) > MaxTokenLength && !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("token of %d bytes: got error %v, want %v", len(token), err, ErrMalformedToken)
+		}
+	})
+}
+
+func FuzzParseRefreshToken(f *testing.F) {
+	now := time.Now().Unix()
+	valid := sign(f, jwt.SigningMethodHS512, &CustomClaimsRefreshToken{
+		User_id:        testUserID,
+		UUID:           "refresh",
+		StandardClaims: jwt.StandardClaims{IssuedAt: now, ExpiresAt: now + 3600},
+	}, []byte(testSecret))
+	for _, seed := range tokenSeeds(valid) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		_, err := ParseRefreshToken(context.Background(), token)
+		if len(token) > MaxTokenLength && !errors.Is(err, ErrMalformedToken) {
+			t.Fatalf("token of %d bytes: got error %v, want %v", len(token), err, ErrMalformedToken)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//UserID is an identifier of user in canonical GUID form, e.g. 0b4f7d2e-9a3c-4e51-8f6d-2c7a1b9e0d34.
+type UserID string
+
+//maxUserIDLength is the length of the longest accepted GUID representation, urn:uuid: prefixed one.
+const maxUserIDLength = 45
+
+//ParseUserID parses user id given as GUID in any case, with or without braces or hyphens, and normalizes it.
+func ParseUserID(s string) (UserID, error) {
+	if s == "" {
+		return "", &ArgumentError{Message: "User id is empty"}
+	}
+	if len(s) > maxUserIDLength {
+		return "", &ArgumentError{Message: "User id is not a valid GUID"}
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return "", &ArgumentError{Message: "User id is not a valid GUID"}
+	}
+	return UserID(id.String()), nil
+}
+
+//String returns canonical form of user id.
+func (id UserID) String() string {
+	return string(id)
+}
+
+//User is an representation of user account that will be stored in mongoDB.
+type User struct {
+	ID           UserID `bson:"_id"`
+	PasswordHash string `bson:"password_hash"`
+	//Roles of the user determine scopes that may be granted to it`s tokens.
+	Roles     []string `bson:"roles,omitempty"`
+	CreatedAt int64    `bson:"created_at"`
+	UpdatedAt int64    `bson:"updated_at"`
+}
+
+//Password length limits. Upper limit protects hashing from abuse with huge inputs.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 1024
+)
+
+//Argon2id parameters as recommended by RFC 9106 for memory constrained environments.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+//ValidatePassword checks password length limits.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return &ArgumentError{Message: fmt.Sprintf("Password must be at least %d characters long", MinPasswordLength)}
+	}
+	if len(password) > MaxPasswordLength {
+		return &ArgumentError{Message: fmt.Sprintf("Password must be at most %d characters long", MaxPasswordLength)}
+	}
+	return nil
+}
+
+//GeneratePasswordHash generates argon2id hash of password encoded in PHC string format.
+func GeneratePasswordHash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		log.Println(err.Error())
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	hash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+	return hash, nil
+}
+
+//ComparePasswordHash checks password against argon2id or bcrypt hash.
+//It returns ErrUnauthenticated if password does not match.
+func ComparePasswordHash(hash, password string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return compareArgon2idHash(hash, password)
+	}
+	//Fallback for bcrypt hashes generated in the same way as GenerateHash does.
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrUnauthenticated
+	}
+	return err
+}
+
+//NeedsRehash reports whether hash should be regenerated with GeneratePasswordHash.
+func NeedsRehash(hash string) bool {
+	var memory uint32
+	var time uint32
+	var threads uint8
+	var version int
+	if _, err := fmt.Sscanf(hash, "$argon2id$v=%d$m=%d,t=%d,p=%d$", &version, &memory, &time, &threads); err != nil {
+		return true
+	}
+	return version != argon2.Version || memory != argon2Memory || time != argon2Time || threads != argon2Threads
+}
+
+func compareArgon2idHash(hash, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return errors.New("Password hash is malformed")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return errors.New("Password hash version is not supported")
+	}
+	var memory uint32
+	var time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return fmt.Errorf("Password hash parameters are malformed: %s", err.Error())
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return fmt.Errorf("Password hash salt is malformed: %s", err.Error())
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return fmt.Errorf("Password hash is malformed: %s", err.Error())
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrUnauthenticated
+	}
+	return nil
+}
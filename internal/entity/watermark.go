@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+//GlobalWatermark is a key of the not before watermark applied to tokens of every user.
+const GlobalWatermark = "*"
+
+//Watermark is a not before watermark, tokens of the user or of everyone issued before it are revoked.
+type Watermark struct {
+	Key       string `bson:"_id"`
+	NotBefore int64  `bson:"not_before"`
+}
+
+//WatermarkSource returns the latest of the global watermark and the watermark of the user, zero if neither is set.
+type WatermarkSource interface {
+	NotBefore(context.Context, UserID) (int64, error)
+}
+
+type watermarkSource struct {
+	source WatermarkSource
+}
+
+var watermarks atomic.Value
+
+//UseWatermarks makes ParseAccessToken, ParseRefreshToken and VerifyOpaque reject tokens issued before not before watermark.
+//Tokens are not checked against watermarks until it is called, nil source turns the check off.
+func UseWatermarks(source WatermarkSource) {
+	watermarks.Store(watermarkSource{source})
+}
+
+//checkNotBefore rejects token of the user issued before or in the same second as not before watermark.
+//Issue time is only known in whole seconds, so tokens issued in the second of the watermark are revoked too,
+//tokens issued after revocation start in the next second. Tokens issued before issuance time was recorded
+//have zero issuedAt and are rejected by any watermark.
+//Watermark which can not be read fails the check, so revoked tokens are never accepted.
+func checkNotBefore(ctx context.Context, userID string, issuedAt int64) error {
+	current, _ := watermarks.Load().(watermarkSource)
+	if current.source == nil {
+		return nil
+	}
+	notBefore, err := current.source.NotBefore(ctx, UserID(userID))
+	if err != nil {
+		return err
+	}
+	if issuedAt <= notBefore {
+		return fmt.Errorf("%w: token was issued before the not before watermark", ErrInvalidToken)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package hashing
+
+import (
+	"context"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//Pool generates bcrypt hashes and runs other slow hashing on a fixed number of worker goroutines, so concurrent hashing
+//can not starve request goroutines of CPU. Work that can not be queued or completed in time fails with entity.ErrOverloaded.
+type Pool struct {
+	jobs    chan job
+	cost    int
+	timeout time.Duration
+}
+
+type job struct {
+	ctx    context.Context
+	fn     func() error
+	result chan error
+}
+
+//NewPool starts workers which hash secrets with given bcrypt cost. At most queue jobs wait for a worker
+//and each of them waits at most timeout from being queued to being done.
+func NewPool(workers, queue int, timeout time.Duration, cost int) *Pool {
+	p := &Pool{
+		jobs:    make(chan job, queue),
+		cost:    cost,
+		timeout: timeout,
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+//Hash generates bcrypt hash of the secret.
+func (p *Pool) Hash(ctx context.Context, secret string) (string, error) {
+	var hash string
+	err := p.Do(ctx, func() error {
+		var err error
+		hash, err = entity.GenerateHash(secret, p.cost)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+//Do runs fn on a worker and returns it`s error. It returns error of the context if the caller gave up
+//and entity.ErrOverloaded if fn could not be queued or done in time, fn may still run then.
+func (p *Pool) Do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	jobCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	j := job{ctx: jobCtx, fn: fn, result: make(chan error, 1)}
+	select {
+	case p.jobs <- j:
+	default:
+		return entity.ErrOverloaded
+	}
+	select {
+	case err := <-j.result:
+		return err
+	case <-jobCtx.Done():
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return entity.ErrOverloaded
+	}
+}
+
+func (p *Pool) work() {
+	for j := range p.jobs {
+		//Caller has already given up, hashing would only delay the rest of the queue.
+		if j.ctx.Err() != nil {
+			continue
+		}
+		j.result <- j.fn()
+	}
+}
@@ -0,0 +1,123 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//benchmarkCost keeps benchmarks short while hashing still dominates the time.
+const benchmarkCost = 8
+
+//benchmarkParallelism is a number of concurrent callers per CPU, more callers than CPUs make hashing compete for them.
+const benchmarkParallelism = 4
+
+func BenchmarkPool(b *testing.B) {
+	b.Run("pool", func(b *testing.B) {
+		p := NewPool(runtime.NumCPU(), benchmarkParallelism*runtime.GOMAXPROCS(0), time.Minute, benchmarkCost)
+		benchmarkLatency(b, func() error {
+			_, err := p.Hash(context.Background(), "secret")
+			return err
+		})
+	})
+	b.Run("direct", func(b *testing.B) {
+		benchmarkLatency(b, func() error {
+			_, err := entity.GenerateHash("secret", benchmarkCost)
+			return err
+		})
+	})
+}
+
+//benchmarkLatency runs fn by concurrent callers and reports median and 99th percentile of it`s latency.
+func benchmarkLatency(b *testing.B, fn func() error) {
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, b.N)
+	b.SetParallelism(benchmarkParallelism)
+	b.RunParallel(func(pb *testing.PB) {
+		local := []time.Duration{}
+		for pb.Next() {
+			start := time.Now()
+			if err := fn(); err != nil {
+				b.Error(err)
+			}
+			local = append(local, time.Since(start))
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(percentile(latencies, 50).Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(percentile(latencies, 99).Nanoseconds()), "p99-ns")
+}
+
+//percentile returns p-th percentile of sorted latencies.
+func percentile(latencies []time.Duration, p int) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	return latencies[(len(latencies)-1)*p/100]
+}
+
+func TestPoolHash(t *testing.T) {
+	p := NewPool(1, 1, time.Minute, benchmarkCost)
+	hash, err := p.Hash(context.Background(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.ComparePasswordHash(hash, "secret"); err != nil {
+		t.Errorf("hash does not match the secret: %v", err)
+	}
+}
+
+func TestPoolCancelled(t *testing.T) {
+	p := NewPool(1, 1, time.Minute, benchmarkCost)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Hash(ctx, "secret"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+
+	//The only worker is busy, so the next job waits in the queue until it`s caller gives up.
+	release := block(p)
+	defer close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Do(ctx, func() error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPoolOverloaded(t *testing.T) {
+	p := NewPool(1, 1, 10*time.Millisecond, benchmarkCost)
+	release := block(p)
+	defer close(release)
+	if err := p.Do(context.Background(), func() error { return nil }); !errors.Is(err, entity.ErrOverloaded) {
+		t.Errorf("timed out job: got %v, want %v", err, entity.ErrOverloaded)
+	}
+
+	//Timed out job still takes the only place in the queue until the worker skips it.
+	if err := p.Do(context.Background(), func() error { return nil }); !errors.Is(err, entity.ErrOverloaded) {
+		t.Errorf("job over the queue: got %v, want %v", err, entity.ErrOverloaded)
+	}
+}
+
+//block keeps the only worker of the pool busy until returned channel is closed.
+func block(p *Pool) chan struct{} {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go p.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	return release
+}
@@ -0,0 +1,122 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//AuditRepository reads audit events from mongoDB and stores events which did not change refresh tokens.
+//Events of changes are stored by token repository in the same transaction as the change.
+type AuditRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewAuditRepository returns a new AuditRepository.
+func NewAuditRepository(cl *mongo.Client, coll string) *AuditRepository {
+	return &AuditRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//Record inserts audit event into mongoDB.
+func (a *AuditRepository) Record(ctx context.Context, event entity.AuditEvent) error {
+	cfg := config.New()
+	log.Printf("Recording %s audit event of user with id=%v. Database name: %s, Collection: %s", event.Type, event.UserID, cfg.DbName, a.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return a.cl.Database(cfg.DbName).Collection(a.collection).InsertOne(sessCtx, &event)
+	}
+
+	session, err := a.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	if _, err := session.WithTransaction(ctx, callback); err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	return nil
+}
+
+//FindAuditEvents returns page of audit events matching the filter from mongoDB in order of time.
+func (a *AuditRepository) FindAuditEvents(ctx context.Context, filter entity.AuditFilter, offset, limit int) ([]entity.AuditEvent, error) {
+	cfg := config.New()
+	log.Printf("Searching audit events in MongoDB. Database name: %s, Collection: %s", cfg.DbName, a.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		opts := options.Find().
+			SetSort(bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64(offset)).
+			SetLimit(int64(limit))
+		cursor, err := a.cl.Database(cfg.DbName).Collection(a.collection).Find(sessCtx, auditFilter(filter), opts)
+		if err != nil {
+			return nil, err
+		}
+		events := []entity.AuditEvent{}
+		if err := cursor.All(sessCtx, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	session, err := a.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.([]entity.AuditEvent), nil
+}
+
+//auditFilter converts entity.AuditFilter into mongoDB filter.
+func auditFilter(filter entity.AuditFilter) bson.M {
+	conditions := bson.M{}
+	if filter.Type != "" {
+		conditions["type"] = filter.Type
+	}
+	if filter.Actor != "" {
+		conditions["actor"] = filter.Actor
+	}
+	if filter.UserID != "" {
+		conditions["user_id"] = filter.UserID
+	}
+	if filter.ClientID != "" {
+		conditions["client_id"] = filter.ClientID
+	}
+	period := bson.M{}
+	if !filter.After.IsZero() {
+		period["$gt"] = filter.After.Unix()
+	}
+	if !filter.Before.IsZero() {
+		period["$lt"] = filter.Before.Unix()
+	}
+	if len(period) > 0 {
+		conditions["time"] = period
+	}
+	return conditions
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes errors of the context through.
+func storageError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
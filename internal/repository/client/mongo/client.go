@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//ClientRepository is an OAuth 2.0 client entity related abstraction for interacting with mongoDB.
+type ClientRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewClientRepository returns a new ClientRepository.
+func NewClientRepository(cl *mongo.Client, coll string) *ClientRepository {
+	return &ClientRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//Create inserts client into mongoDB.
+func (c *ClientRepository) Create(ctx context.Context, client *entity.Client) error {
+	cfg := config.New()
+	log.Printf("Inserting client with id=%v into mongoDB. Database name: %s, Collection: %s", client.ID, cfg.DbName, c.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := c.cl.Database(cfg.DbName).Collection(c.collection).InsertOne(sessCtx, client); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	session, err := c.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	log.Println("Client was successfully stored in mongoDB")
+	return nil
+}
+
+//Get returns client by given id.
+//It returns entity.ErrClientNotFound if there is no such client.
+func (c *ClientRepository) Get(ctx context.Context, clientID string) (*entity.Client, error) {
+	cfg := config.New()
+	log.Printf("Searching client with id=%v in MongoDB. Database name: %s, Collection: %s", clientID, cfg.DbName, c.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		client := &entity.Client{}
+		err := c.cl.Database(cfg.DbName).Collection(c.collection).FindOne(sessCtx, bson.M{"_id": clientID}).Decode(client)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, entity.ErrClientNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	session, err := c.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.Client), nil
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes domain errors and errors of the context through.
+func storageError(err error) error {
+	if errors.Is(err, entity.ErrClientNotFound) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
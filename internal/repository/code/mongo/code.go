@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//CodeRepository is an authorization code entity related abstraction for interacting with mongoDB.
+type CodeRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewCodeRepository returns a new CodeRepository.
+func NewCodeRepository(cl *mongo.Client, coll string) *CodeRepository {
+	return &CodeRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//Insert inserts authorization code into mongoDB.
+func (c *CodeRepository) Insert(ctx context.Context, code *entity.AuthorizationCode) error {
+	cfg := config.New()
+	log.Printf("Inserting authorization code of client with id=%v into mongoDB. Database name: %s, Collection: %s", code.ClientID, cfg.DbName, c.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := c.cl.Database(cfg.DbName).Collection(c.collection).InsertOne(sessCtx, code); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	session, err := c.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	log.Println("Authorization code was successfully stored in mongoDB")
+	return nil
+}
+
+//Take deletes authorization code with given hash from mongoDB and returns it.
+//It returns entity.ErrCodeNotFound if there is no such code.
+func (c *CodeRepository) Take(ctx context.Context, hash string) (*entity.AuthorizationCode, error) {
+	cfg := config.New()
+	log.Printf("Taking authorization code from MongoDB. Database name: %s, Collection: %s", cfg.DbName, c.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		code := &entity.AuthorizationCode{}
+		err := c.cl.Database(cfg.DbName).Collection(c.collection).FindOneAndDelete(sessCtx, bson.M{"_id": hash}).Decode(code)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, entity.ErrCodeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return code, nil
+	}
+
+	session, err := c.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.AuthorizationCode), nil
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes domain errors and errors of the context through.
+func storageError(err error) error {
+	if errors.Is(err, entity.ErrCodeNotFound) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
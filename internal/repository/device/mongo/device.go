@@ -0,0 +1,236 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//DeviceRepository is a device code entity related abstraction for interacting with mongoDB.
+type DeviceRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewDeviceRepository returns a new DeviceRepository.
+func NewDeviceRepository(cl *mongo.Client, coll string) *DeviceRepository {
+	return &DeviceRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//Insert inserts device code into mongoDB.
+func (d *DeviceRepository) Insert(ctx context.Context, code *entity.DeviceCode) error {
+	cfg := config.New()
+	log.Printf("Inserting device code of client with id=%v into mongoDB. Database name: %s, Collection: %s", code.ClientID, cfg.DbName, d.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := d.cl.Database(cfg.DbName).Collection(d.collection).InsertOne(sessCtx, code); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	session, err := d.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	log.Println("Device code was successfully stored in mongoDB")
+	return nil
+}
+
+//GetByUserCode finds device code by hash of it`s user code.
+//It returns entity.ErrCodeNotFound if there is no such code.
+func (d *DeviceRepository) GetByUserCode(ctx context.Context, hash string) (*entity.DeviceCode, error) {
+	cfg := config.New()
+	log.Printf("Getting device code by user code from MongoDB. Database name: %s, Collection: %s", cfg.DbName, d.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		code := &entity.DeviceCode{}
+		err := d.cl.Database(cfg.DbName).Collection(d.collection).FindOne(sessCtx, bson.M{"user_code_hash": hash}).Decode(code)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, entity.ErrCodeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return code, nil
+	}
+
+	session, err := d.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.DeviceCode), nil
+}
+
+//Decide marks pending device code with given user code hash as approved or denied by the user.
+//It returns entity.ErrCodeNotFound if there is no such code and entity.ErrCodeUsed if the code is not pending anymore.
+func (d *DeviceRepository) Decide(ctx context.Context, hash string, userID entity.UserID, approved bool) error {
+	cfg := config.New()
+	log.Printf("Recording decision of user with id=%v on device code in MongoDB. Database name: %s, Collection: %s", userID, cfg.DbName, d.collection)
+
+	status := entity.DeviceCodeDenied
+	if approved {
+		status = entity.DeviceCodeApproved
+	}
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		coll := d.cl.Database(cfg.DbName).Collection(d.collection)
+		result, err := coll.UpdateOne(sessCtx,
+			bson.M{"user_code_hash": hash, "status": entity.DeviceCodePending},
+			bson.M{"$set": bson.M{"status": status, "user_id": userID}})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount != 0 {
+			return nil, nil
+		}
+		count, err := coll.CountDocuments(sessCtx, bson.M{"user_code_hash": hash})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, entity.ErrCodeNotFound
+		}
+		return nil, entity.ErrCodeUsed
+	}
+
+	session, err := d.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	log.Printf("Device code was successfully marked as %s", status)
+	return nil
+}
+
+//Poll sets polling time of device code with given hash and returns the code as it was before the update.
+//It returns entity.ErrCodeNotFound if there is no such code.
+func (d *DeviceRepository) Poll(ctx context.Context, hash string, now time.Time) (*entity.DeviceCode, error) {
+	cfg := config.New()
+	log.Printf("Polling device code in MongoDB. Database name: %s, Collection: %s", cfg.DbName, d.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		code := &entity.DeviceCode{}
+		err := d.cl.Database(cfg.DbName).Collection(d.collection).FindOneAndUpdate(sessCtx,
+			bson.M{"_id": hash},
+			bson.M{"$set": bson.M{"last_polled_at": now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(code)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, entity.ErrCodeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return code, nil
+	}
+
+	session, err := d.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.DeviceCode), nil
+}
+
+//SlowDown increases polling interval of device code with given hash by entity.DeviceSlowDown seconds.
+func (d *DeviceRepository) SlowDown(ctx context.Context, hash string) error {
+	cfg := config.New()
+	log.Printf("Increasing polling interval of device code in MongoDB. Database name: %s, Collection: %s", cfg.DbName, d.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		_, err := d.cl.Database(cfg.DbName).Collection(d.collection).UpdateOne(sessCtx,
+			bson.M{"_id": hash},
+			bson.M{"$inc": bson.M{"interval": entity.DeviceSlowDown}})
+		return nil, err
+	}
+
+	session, err := d.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	return nil
+}
+
+//Take deletes device code with given hash from mongoDB and returns it.
+//It returns entity.ErrCodeNotFound if there is no such code.
+func (d *DeviceRepository) Take(ctx context.Context, hash string) (*entity.DeviceCode, error) {
+	cfg := config.New()
+	log.Printf("Taking device code from MongoDB. Database name: %s, Collection: %s", cfg.DbName, d.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		code := &entity.DeviceCode{}
+		err := d.cl.Database(cfg.DbName).Collection(d.collection).FindOneAndDelete(sessCtx, bson.M{"_id": hash}).Decode(code)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, entity.ErrCodeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return code, nil
+	}
+
+	session, err := d.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.DeviceCode), nil
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes domain errors and errors of the context through.
+func storageError(err error) error {
+	if errors.Is(err, entity.ErrCodeNotFound) || errors.Is(err, entity.ErrCodeUsed) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//sweepInterval is how often full buckets are forgotten.
+const sweepInterval = time.Minute
+
+//RateLimitRepository keeps rate limiting token buckets in memory of a single instance of the service.
+type RateLimitRepository struct {
+	mu        sync.Mutex
+	buckets   map[string]*entity.Bucket
+	lastSweep time.Time
+}
+
+//NewRateLimitRepository returns a new RateLimitRepository.
+func NewRateLimitRepository() *RateLimitRepository {
+	return &RateLimitRepository{
+		buckets: map[string]*entity.Bucket{},
+	}
+}
+
+//Take takes a token from buckets with given keys if all of them have one.
+func (r *RateLimitRepository) Take(ctx context.Context, keys []string, limit entity.RateLimit, now time.Time) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now)
+	buckets := make([]*entity.Bucket, len(keys))
+	var wait time.Duration
+	for i, key := range keys {
+		bucket, ok := r.buckets[key]
+		if !ok {
+			bucket = &entity.Bucket{Key: key}
+			r.buckets[key] = bucket
+		}
+		buckets[i] = bucket
+		if w := bucket.Peek(limit, now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+	for _, bucket := range buckets {
+		bucket.Take(limit, now)
+	}
+	return 0, nil
+}
+
+//sweep forgets buckets which are full again, so memory is only held for recently limited keys.
+func (r *RateLimitRepository) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for key, bucket := range r.buckets {
+		if !bucket.ExpiresAt.After(now) {
+			delete(r.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+func TestTake(t *testing.T) {
+	limit := entity.RateLimit{Requests: 2, Period: 2 * time.Second}
+	start := time.Unix(1600000000, 0)
+	//Takes are done in order on the same repository.
+	tests := []struct {
+		name string
+		keys []string
+		at   time.Duration
+		wait time.Duration
+	}{
+		{"new buckets", []string{"ip", "user"}, 0, 0},
+		{"user bucket is emptied", []string{"user"}, 0, 0},
+		{"rejected by user bucket", []string{"ip", "user"}, 0, time.Second},
+		{"ip bucket is left for other users", []string{"ip", "other user"}, 0, 0},
+		{"both buckets are empty", []string{"ip", "user"}, 0, time.Second},
+		{"refilled", []string{"ip", "user"}, time.Second, 0},
+		{"no keys", nil, time.Second, 0},
+		{"empty again", []string{"ip", "user"}, time.Second, time.Second},
+		{"full buckets are forgotten", []string{"user", "user"}, time.Hour, 0},
+	}
+	r := NewRateLimitRepository()
+	for _, tt := range tests {
+		wait, err := r.Take(context.Background(), tt.keys, limit, start.Add(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != tt.wait {
+			t.Fatalf("%s: got wait %s, want %s", tt.name, wait, tt.wait)
+		}
+	}
+	if len(r.buckets) != 1 {
+		t.Errorf("got %d buckets, want only the one taken from after sweep", len(r.buckets))
+	}
+}
@@ -0,0 +1,87 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//RateLimitRepository keeps rate limiting token buckets in mongoDB, so they are shared by all instances of the service.
+//Buckets are removed by mongoDB TTL index on expires_at once they are full again.
+type RateLimitRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewRateLimitRepository returns a new RateLimitRepository.
+func NewRateLimitRepository(cl *mongo.Client, coll string) *RateLimitRepository {
+	return &RateLimitRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//Take takes a token from buckets with given keys if all of them have one.
+//Concurrent takes of the same bucket conflict and are retried by the transaction.
+func (r *RateLimitRepository) Take(ctx context.Context, keys []string, limit entity.RateLimit, now time.Time) (time.Duration, error) {
+	cfg := config.New()
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		coll := r.cl.Database(cfg.DbName).Collection(r.collection)
+		buckets := make([]*entity.Bucket, len(keys))
+		var wait time.Duration
+		for i, key := range keys {
+			bucket := &entity.Bucket{}
+			err := coll.FindOne(sessCtx, bson.M{"_id": key}).Decode(bucket)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				bucket = &entity.Bucket{Key: key}
+			} else if err != nil {
+				return nil, err
+			}
+			buckets[i] = bucket
+			if w := bucket.Peek(limit, now); w > wait {
+				wait = w
+			}
+		}
+		//Rejected request leaves buckets as they are, refill is computed again by the next one.
+		if wait > 0 {
+			return wait, nil
+		}
+		for _, bucket := range buckets {
+			bucket.Take(limit, now)
+			if _, err := coll.ReplaceOne(sessCtx, bson.M{"_id": bucket.Key}, bucket, options.Replace().SetUpsert(true)); err != nil {
+				return nil, err
+			}
+		}
+		return time.Duration(0), nil
+	}
+
+	session, err := r.cl.StartSession()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return 0, storageError(err)
+	}
+	return result.(time.Duration), nil
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes errors of the context through.
+func storageError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//connect returns client of replica set at MONGO_TEST_URI, the test is skipped if it is not set.
+func connect(t *testing.T) *mongo.Client {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx := context.Background()
+	cl, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cl.Disconnect(ctx) })
+	return cl
+}
+
+func TestTake(t *testing.T) {
+	cl := connect(t)
+	ctx := context.Background()
+	db := cl.Database(config.New().DbName)
+	r := NewRateLimitRepository(cl, "rate_limits_"+uuid.New().String())
+	//Collections can not be created implicitly inside transactions by older servers.
+	if _, err := db.Collection(r.collection).InsertOne(ctx, bson.M{"_id": "init"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Collection(r.collection).Drop(ctx) })
+
+	limit := entity.RateLimit{Requests: 2, Period: 2 * time.Second}
+	//Stored times are truncated to milliseconds.
+	start := time.Now().Truncate(time.Millisecond)
+	//Takes are done in order on the same collection.
+	tests := []struct {
+		name string
+		keys []string
+		at   time.Duration
+		wait time.Duration
+	}{
+		{"new buckets", []string{"ip", "user"}, 0, 0},
+		{"user bucket is emptied", []string{"user"}, 0, 0},
+		{"rejected by user bucket", []string{"ip", "user"}, 0, time.Second},
+		{"ip bucket is left for other users", []string{"ip", "other user"}, 0, 0},
+		{"both buckets are empty", []string{"ip", "user"}, 0, time.Second},
+		{"refilled", []string{"ip", "user"}, time.Second, 0},
+		{"no keys", nil, time.Second, 0},
+		{"empty again", []string{"ip", "user"}, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		wait, err := r.Take(ctx, tt.keys, limit, start.Add(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != tt.wait {
+			t.Fatalf("%s: got wait %s, want %s", tt.name, wait, tt.wait)
+		}
+	}
+
+	bucket := &entity.Bucket{}
+	if err := db.Collection(r.collection).FindOne(ctx, bson.M{"_id": "ip"}).Decode(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(3 * time.Second); !bucket.ExpiresAt.Equal(want) {
+		t.Errorf("got ip bucket full at %s, want %s", bucket.ExpiresAt, want)
+	}
+}
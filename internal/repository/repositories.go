@@ -2,16 +2,91 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"example.com/auth-service-go/internal/entity"
 )
 
-//Token is an interface which abstracts interaction with databases that interacts with tokens
+//Token is an interface which abstracts interaction with databases that interacts with tokens.
+//Implementations report failures with the domain errors declared in package entity.
+//Methods changing refresh tokens record given audit event in the same transaction as the change,
+//completed with the user, client, session and ids of the affected tokens.
 type Token interface {
-	Insert(context.Context, *entity.TokenPair) error
-	DeleteUserRefreshTokens(context.Context, string) error
-	DeleteRefreshToken(context.Context, string, string) error
-	IsUserInDB(context.Context, string) bool
-	IsRefreshTokenInDB(context.Context, string) bool
-	RefreshTokenSetIsUsed(context.Context, string) error
+	Insert(context.Context, *entity.TokenPair, entity.AuditEvent) error
+	DeleteUserRefreshTokens(context.Context, entity.UserID, entity.AuditEvent) error
+	DeleteClientRefreshTokens(context.Context, string, entity.AuditEvent) error
+	DeleteRefreshToken(context.Context, entity.UserID, string, entity.AuditEvent) error
+	CheckRefreshToken(context.Context, string) error
+	//GetRefreshToken returns unused refresh token with given id.
+	GetRefreshToken(context.Context, string) (*entity.RefreshToken, error)
+	RefreshTokenSetIsUsed(context.Context, string, entity.AuditEvent) error
+	//UserRefreshTokens returns unused refresh tokens of the user, one for every session.
+	UserRefreshTokens(context.Context, entity.UserID) ([]entity.RefreshToken, error)
+	//DeleteSession deletes all refresh tokens of the user`s session with given id.
+	DeleteSession(context.Context, entity.UserID, string, entity.AuditEvent) error
+	//FindRefreshTokens returns at most limit refresh tokens matching the filter after skipping offset of them,
+	//the most recently issued first.
+	FindRefreshTokens(ctx context.Context, filter entity.TokenFilter, offset, limit int) ([]entity.RefreshToken, error)
+	//DeleteRefreshTokens deletes all refresh tokens matching the filter and returns their number.
+	DeleteRefreshTokens(context.Context, entity.TokenFilter, entity.AuditEvent) (int64, error)
+}
+
+//User is an interface which abstracts interaction with databases that interacts with user accounts.
+type User interface {
+	Create(context.Context, *entity.User) error
+	Get(context.Context, entity.UserID) (*entity.User, error)
+	UpdatePassword(context.Context, entity.UserID, string) error
+}
+
+//Client is an interface which abstracts interaction with databases that interacts with OAuth 2.0 clients.
+type Client interface {
+	Create(context.Context, *entity.Client) error
+	Get(context.Context, string) (*entity.Client, error)
+}
+
+//AuthorizationCode is an interface which abstracts interaction with databases that interacts with OAuth 2.0 authorization codes.
+type AuthorizationCode interface {
+	Insert(context.Context, *entity.AuthorizationCode) error
+	//Take deletes authorization code with given hash and returns it, so every code can only be redeemed once.
+	Take(context.Context, string) (*entity.AuthorizationCode, error)
+}
+
+//DeviceCode is an interface which abstracts interaction with databases that interacts with OAuth 2.0 device codes.
+type DeviceCode interface {
+	Insert(context.Context, *entity.DeviceCode) error
+	//GetByUserCode returns device code by hash of it`s user code.
+	GetByUserCode(context.Context, string) (*entity.DeviceCode, error)
+	//Decide records decision of the user on pending device code with given user code hash.
+	Decide(context.Context, string, entity.UserID, bool) error
+	//Poll records polling time of device code with given hash and returns device code as it was before.
+	Poll(context.Context, string, time.Time) (*entity.DeviceCode, error)
+	//SlowDown increases polling interval of device code with given hash.
+	SlowDown(context.Context, string) error
+	//Take deletes device code with given hash and returns it, so every code can only be redeemed once.
+	Take(context.Context, string) (*entity.DeviceCode, error)
+}
+
+//RateLimit is an interface which abstracts storage of rate limiting token buckets.
+type RateLimit interface {
+	//Take takes a token from each bucket with given keys refilled according to the limit at given time,
+	//but only if all of them have one. Otherwise no token is taken and the longest time until the next token is returned.
+	Take(context.Context, []string, entity.RateLimit, time.Time) (time.Duration, error)
+}
+
+//Watermark is an interface which abstracts storage of not before watermarks, tokens issued before them are revoked.
+type Watermark interface {
+	//NotBefore returns the latest of the global watermark and the watermark of the user, zero if neither is set.
+	//Empty user id returns the global watermark only.
+	NotBefore(context.Context, entity.UserID) (int64, error)
+	//SetNotBefore moves the watermark of the user, or the global watermark if user id is empty, forward to given time.
+	//Watermark is never moved back, the resulting watermark is returned.
+	SetNotBefore(context.Context, entity.UserID, int64) (int64, error)
+}
+
+//Audit is an interface which abstracts append-only storage of audit events.
+type Audit interface {
+	//Record stores event which did not change refresh tokens, such as failed operation.
+	Record(context.Context, entity.AuditEvent) error
+	//FindAuditEvents returns at most limit events matching the filter after skipping offset of them, in order of time.
+	FindAuditEvents(ctx context.Context, filter entity.AuditFilter, offset, limit int) ([]entity.AuditEvent, error)
 }
@@ -3,158 +3,227 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 
 	"example.com/auth-service-go/config"
 	"example.com/auth-service-go/internal/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+//Hasher generates bcrypt hashes of refresh tokens.
+type Hasher interface {
+	Hash(context.Context, string) (string, error)
+}
+
 //TokenRepository is an token entity related abstraction for interacting with mongoDB.
+//Every change of refresh tokens is recorded in audit collection in the same transaction.
 type TokenRepository struct {
 	cl         *mongo.Client
 	collection string
+	audit      string
+	//locks holds a document per user and per user with client, which is written before active sessions are counted.
+	locks string
+	//limit is enforced whenever refresh token is inserted.
+	limit  entity.SessionLimit
+	hasher Hasher
 }
 
 //NewTokenRepository returns a new TokenRepository.
-func NewTokenRepository(cl *mongo.Client, coll string) *TokenRepository {
+func NewTokenRepository(cl *mongo.Client, coll, auditColl, locksColl string, limit entity.SessionLimit, hasher Hasher) *TokenRepository {
 	return &TokenRepository{
 		cl:         cl,
 		collection: coll,
+		audit:      auditColl,
+		locks:      locksColl,
+		limit:      limit,
+		hasher:     hasher,
 	}
 }
 
 //Insert inserts pair of tokens into mongoDB.
-func (t *TokenRepository) Insert(ctx context.Context, tokenPair *entity.TokenPair) error {
+func (t *TokenRepository) Insert(ctx context.Context, tokenPair *entity.TokenPair, event entity.AuditEvent) error {
 	cfg := config.New()
 	log.Printf("Inserting tokens into mongoDB. Database name: %s, Collection: %s", cfg.DbName, t.collection)
 
-	//Convert refresh token into bcrypt hash before inserting it in mongoDB.
-	refreshTokenHash, err := entity.GenerateHash(tokenPair.RefreshToken.Token)
-	if err != nil {
-		return errors.New("Error generating hash for refresh token")
+	//Convert jwt refresh token into bcrypt hash before inserting it in mongoDB.
+	//Opaque refresh token is random, so keyed digest protects it as well and is much cheaper to compute.
+	refreshTokenHash := entity.RefreshTokenDigest(tokenPair.RefreshToken.Token)
+	if !entity.IsOpaqueRefreshToken(tokenPair.RefreshToken.Token) {
+		var err error
+		refreshTokenHash, err = t.hasher.Hash(ctx, tokenPair.RefreshToken.Token)
+		if err != nil {
+			return fmt.Errorf("Error generating hash for refresh token: %w", err)
+		}
 	}
 
 	//Insert refresh token into mongoDB.
-	refreshToken := entity.RefreshToken{
-		UUID:      tokenPair.RefreshToken.UUID,
-		UserID:    tokenPair.RefreshToken.UserID,
-		Token:     refreshTokenHash,
-		ExpiresAt: tokenPair.RefreshToken.ExpiresAt,
-		Used:      tokenPair.RefreshToken.Used,
-	}
+	refreshToken := tokenPair.RefreshToken
+	refreshToken.Token = refreshTokenHash
+	event.UserID = refreshToken.UserID
+	event.ClientID = refreshToken.ClientID
+	event.SessionID = refreshToken.SessionID
+	event.TokenIDs = []string{refreshToken.UUID}
 	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
-		if _, err := t.cl.Database(cfg.DbName).Collection(t.collection).InsertOne(sessCtx, &refreshToken); err != nil {
+		coll := t.cl.Database(cfg.DbName).Collection(t.collection)
+		if err := t.limitSessions(sessCtx, coll, &refreshToken, event); err != nil {
+			return nil, err
+		}
+		if _, err := coll.InsertOne(sessCtx, &refreshToken); err != nil {
 			return nil, err
 		}
-		return nil, nil
+		return nil, t.record(sessCtx, event)
 	}
 
 	session, err := t.cl.StartSession()
 	if err != nil {
-		panic(err)
+		return storageError(err)
 	}
 	defer session.EndSession(ctx)
 
 	_, err = session.WithTransaction(ctx, callback)
 	if err != nil {
 		log.Println(err.Error())
-		return err
+		return storageError(err)
 	}
 	log.Println("Tokens were successfully stored in mongoDB")
 	return nil
 }
 
 //DeleteRefreshToken deletes particular refresh token from mongoDB.
-func (t *TokenRepository) DeleteRefreshToken(ctx context.Context, userID, refreshTokenUUID string) error {
+func (t *TokenRepository) DeleteRefreshToken(ctx context.Context, userID entity.UserID, refreshTokenUUID string, event entity.AuditEvent) error {
 	cfg := config.New()
 	log.Printf("Deleting refresh token: %s from MongoDB. Database name: %s, Collection: %s", refreshTokenUUID, cfg.DbName, t.collection)
 
+	event.UserID = userID
+	event.TokenIDs = []string{refreshTokenUUID}
 	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
 		filter := bson.M{"_id": refreshTokenUUID, "user_id": userID}
-		result, err := t.cl.Database(cfg.DbName).Collection(t.collection).DeleteOne(sessCtx, filter)
-		if err != nil {
-			return nil, err
-		}
-		return result, nil
+		return t.deleteRecorded(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), filter, event)
 	}
 
 	session, err := t.cl.StartSession()
 	if err != nil {
-		panic(err)
+		return storageError(err)
 	}
 	defer session.EndSession(ctx)
 
-	_, err = session.WithTransaction(ctx, callback)
+	result, err := session.WithTransaction(ctx, callback)
 	if err != nil {
 		log.Println(err.Error())
-		return err
+		return storageError(err)
 	}
 
+	if result.(*mongo.DeleteResult).DeletedCount == 0 {
+		log.Println("There was no such refresh token in mongoDB")
+		return entity.ErrTokenNotFound
+	}
 	log.Println("Refresh token was successfully deleted from mongoDB")
 	return nil
 }
 
 //RefreshTokenSetIsUsed sets field used to true for particular refresh token.
-func (t *TokenRepository) RefreshTokenSetIsUsed(ctx context.Context, refreshTokenUUID string) error {
+//It returns entity.ErrTokenNotFound if there is no such token and entity.ErrTokenUsed if it was already used.
+func (t *TokenRepository) RefreshTokenSetIsUsed(ctx context.Context, refreshTokenUUID string, event entity.AuditEvent) error {
 	cfg := config.New()
 	log.Printf("Updating refresh token: %s in MongoDB. Database name: %s, Collection: %s", refreshTokenUUID, cfg.DbName, t.collection)
 
 	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
-		refreshTokenFilter := bson.M{"_id": refreshTokenUUID}
-		result, err := t.cl.Database(cfg.DbName).Collection(t.collection).UpdateOne(sessCtx, refreshTokenFilter, bson.M{"$set": bson.M{"used": true}})
+		coll := t.cl.Database(cfg.DbName).Collection(t.collection)
+		refreshToken, err := findRefreshToken(sessCtx, coll, refreshTokenUUID)
 		if err != nil {
 			return nil, err
 		}
-		return result, nil
+		refreshTokenFilter := bson.M{"_id": refreshTokenUUID, "used": false}
+		result, err := coll.UpdateOne(sessCtx, refreshTokenFilter, bson.M{"$set": bson.M{"used": true}})
+		if err != nil {
+			return nil, err
+		}
+		if result.ModifiedCount == 0 {
+			return result, nil
+		}
+		event.UserID = refreshToken.UserID
+		event.ClientID = refreshToken.ClientID
+		event.SessionID = refreshToken.SessionID
+		if event.SessionID == "" {
+			event.SessionID = refreshToken.UUID
+		}
+		event.TokenIDs = []string{refreshToken.UUID}
+		return result, t.record(sessCtx, event)
 	}
 
 	session, err := t.cl.StartSession()
 	if err != nil {
-		panic(err)
+		return storageError(err)
 	}
 	defer session.EndSession(ctx)
 
 	result, err := session.WithTransaction(ctx, callback)
 	if err != nil {
 		log.Println(err.Error())
-		return err
+		return storageError(err)
 	}
 
 	updated := int(result.(*mongo.UpdateResult).ModifiedCount)
 	if updated == 0 {
-		log.Println("There was no such refresh token in mongoDB")
-		return errors.New("There was no such refresh token")
+		log.Println("Refresh token has already been used")
+		return entity.ErrTokenUsed
 	}
 	log.Println("Refresh token has been successfully updated")
 	return nil
 }
 
 //DeleteUserRefreshTokens deletes all tokens from mongoDB that relates to particular user.
-func (t *TokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+func (t *TokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID entity.UserID, event entity.AuditEvent) error {
 	cfg := config.New()
 	log.Printf("Deleting all tokens from MongoDB related to user with id=%v. Database name: %s, Collection: %s.", userID, cfg.DbName, t.collection)
 
+	event.UserID = userID
 	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
 		filter := bson.M{"user_id": userID}
-		result, err := t.cl.Database(cfg.DbName).Collection(t.collection).DeleteMany(sessCtx, filter)
-		if err != nil {
-			return nil, err
-		}
-		return result, nil
+		return t.deleteRecorded(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), filter, event)
 	}
 
 	session, err := t.cl.StartSession()
 	if err != nil {
-		panic(err)
+		return storageError(err)
 	}
 	defer session.EndSession(ctx)
 
 	result, err := session.WithTransaction(ctx, callback)
 	if err != nil {
 		log.Println(err.Error())
-		return err
+		return storageError(err)
+	}
+
+	deletedCount := int(result.(*mongo.DeleteResult).DeletedCount)
+	log.Printf("%v records was deleted from mongoDB", deletedCount)
+	return nil
+}
+
+//DeleteClientRefreshTokens deletes all tokens from mongoDB that were issued to particular OAuth 2.0 client.
+func (t *TokenRepository) DeleteClientRefreshTokens(ctx context.Context, clientID string, event entity.AuditEvent) error {
+	cfg := config.New()
+	log.Printf("Deleting all tokens from MongoDB issued to client with id=%v. Database name: %s, Collection: %s.", clientID, cfg.DbName, t.collection)
+
+	event.ClientID = clientID
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.M{"client_id": clientID}
+		return t.deleteRecorded(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), filter, event)
+	}
+
+	session, err := t.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
 	}
 
 	deletedCount := int(result.(*mongo.DeleteResult).DeletedCount)
@@ -162,69 +231,365 @@ func (t *TokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID st
 	return nil
 }
 
-//IsUserInDB check existence of particular user by given id in mongoDB.
-func (t *TokenRepository) IsUserInDB(ctx context.Context, userID string) bool {
-	if userID == "" {
-		return false
+//CheckRefreshToken checks existence of particular unused refresh token in mongoDB.
+//It returns entity.ErrTokenNotFound if there is no such token and entity.ErrTokenUsed if it was already used.
+func (t *TokenRepository) CheckRefreshToken(ctx context.Context, refreshTokenUUID string) error {
+	if refreshTokenUUID == "" {
+		return entity.ErrTokenNotFound
 	}
 	cfg := config.New()
-	log.Printf("Searching user with id=%v in MongoDB. Database name: %s, Collection: %s", userID, cfg.DbName, t.collection)
+	log.Printf("Searching refresh token with id=%v in MongoDB. Database name: %s, Collection: %s", refreshTokenUUID, cfg.DbName, t.collection)
 
 	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
-		filter := bson.M{"user_id": userID}
-		result := t.cl.Database(cfg.DbName).Collection(t.collection).FindOne(sessCtx, filter)
-		//Check in case of no documents was found.
-		if err := result.Err(); err != nil {
-			return nil, err
-		}
-		return result, nil
+		_, err := findRefreshToken(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), refreshTokenUUID)
+		return nil, err
 	}
 
 	session, err := t.cl.StartSession()
 	if err != nil {
-		panic(err)
+		return storageError(err)
 	}
 	defer session.EndSession(ctx)
 
 	_, err = session.WithTransaction(ctx, callback)
-	if err == mongo.ErrNoDocuments {
-		log.Println("There is no such user in MongoDB")
-		return false
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
 	}
-	log.Println("There is such user in mongoDB")
-	return true
+	log.Println("There is such refresh token in mongoDB")
+	return nil
 }
 
-//IsRefreshTokenInDB checks existence of particular unused refresh token in mongoDB.
-func (t *TokenRepository) IsRefreshTokenInDB(ctx context.Context, refreshTokenUUID string) bool {
+//GetRefreshToken finds particular unused refresh token in mongoDB.
+//It returns entity.ErrTokenNotFound if there is no such token and entity.ErrTokenUsed if it was already used.
+func (t *TokenRepository) GetRefreshToken(ctx context.Context, refreshTokenUUID string) (*entity.RefreshToken, error) {
 	if refreshTokenUUID == "" {
-		return false
+		return nil, entity.ErrTokenNotFound
 	}
 	cfg := config.New()
-	log.Printf("Searching refresh token with id=%v in MongoDB. Database name: %s, Collection: %s", refreshTokenUUID, cfg.DbName, t.collection)
+	log.Printf("Getting refresh token with id=%v from MongoDB. Database name: %s, Collection: %s", refreshTokenUUID, cfg.DbName, t.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return findRefreshToken(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), refreshTokenUUID)
+	}
+
+	session, err := t.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.RefreshToken), nil
+}
+
+//UserRefreshTokens finds all unused refresh tokens of particular user in mongoDB.
+func (t *TokenRepository) UserRefreshTokens(ctx context.Context, userID entity.UserID) ([]entity.RefreshToken, error) {
+	cfg := config.New()
+	log.Printf("Searching refresh tokens of user with id=%v in MongoDB. Database name: %s, Collection: %s", userID, cfg.DbName, t.collection)
 
 	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
-		//This filter makes it seems like only unused refresh tokens are in mongoDB.
-		filter := bson.M{"_id": refreshTokenUUID, "used": false}
-		result := t.cl.Database(cfg.DbName).Collection(t.collection).FindOne(sessCtx, filter)
-		//Check in case of no documents was found.
-		if err := result.Err(); err != nil {
+		filter := bson.M{"user_id": userID, "used": false}
+		cursor, err := t.cl.Database(cfg.DbName).Collection(t.collection).Find(sessCtx, filter, options.Find().SetSort(bson.M{"created_at": -1}))
+		if err != nil {
 			return nil, err
 		}
-		return result, nil
+		refreshTokens := []entity.RefreshToken{}
+		if err := cursor.All(sessCtx, &refreshTokens); err != nil {
+			return nil, err
+		}
+		return refreshTokens, nil
 	}
 
 	session, err := t.cl.StartSession()
 	if err != nil {
-		panic(err)
+		return nil, storageError(err)
 	}
 	defer session.EndSession(ctx)
 
-	_, err = session.WithTransaction(ctx, callback)
-	if err == mongo.ErrNoDocuments {
-		log.Println("There is no such refresh token in MongoDB")
-		return false
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
 	}
-	log.Println("There is such refresh token in mongoDB")
-	return true
+	return result.([]entity.RefreshToken), nil
+}
+
+//DeleteSession deletes all refresh tokens of particular session of the user from mongoDB.
+//It returns entity.ErrSessionNotFound if the user has no such session.
+func (t *TokenRepository) DeleteSession(ctx context.Context, userID entity.UserID, sessionID string, event entity.AuditEvent) error {
+	cfg := config.New()
+	log.Printf("Deleting session: %s of user with id=%v from MongoDB. Database name: %s, Collection: %s", sessionID, userID, cfg.DbName, t.collection)
+
+	event.UserID = userID
+	event.SessionID = sessionID
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return t.deleteRecorded(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), sessionFilter(userID, sessionID), event)
+	}
+
+	session, err := t.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+
+	if result.(*mongo.DeleteResult).DeletedCount == 0 {
+		log.Println("There was no such session in mongoDB")
+		return entity.ErrSessionNotFound
+	}
+	log.Println("Session was successfully deleted from mongoDB")
+	return nil
+}
+
+//FindRefreshTokens returns page of refresh tokens matching the filter from mongoDB, the most recently issued first.
+func (t *TokenRepository) FindRefreshTokens(ctx context.Context, filter entity.TokenFilter, offset, limit int) ([]entity.RefreshToken, error) {
+	cfg := config.New()
+	log.Printf("Searching refresh tokens in MongoDB. Database name: %s, Collection: %s", cfg.DbName, t.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		opts := options.Find().
+			SetSort(bson.D{{Key: "rotated_at", Value: -1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64(offset)).
+			SetLimit(int64(limit))
+		cursor, err := t.cl.Database(cfg.DbName).Collection(t.collection).Find(sessCtx, tokenFilter(filter), opts)
+		if err != nil {
+			return nil, err
+		}
+		refreshTokens := []entity.RefreshToken{}
+		if err := cursor.All(sessCtx, &refreshTokens); err != nil {
+			return nil, err
+		}
+		return refreshTokens, nil
+	}
+
+	session, err := t.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.([]entity.RefreshToken), nil
+}
+
+//DeleteRefreshTokens deletes all refresh tokens matching the filter from mongoDB in one transaction.
+func (t *TokenRepository) DeleteRefreshTokens(ctx context.Context, filter entity.TokenFilter, event entity.AuditEvent) (int64, error) {
+	cfg := config.New()
+	log.Printf("Deleting refresh tokens from MongoDB. Database name: %s, Collection: %s", cfg.DbName, t.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return t.deleteRecorded(sessCtx, t.cl.Database(cfg.DbName).Collection(t.collection), tokenFilter(filter), event)
+	}
+
+	session, err := t.cl.StartSession()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return 0, storageError(err)
+	}
+
+	deletedCount := result.(*mongo.DeleteResult).DeletedCount
+	log.Printf("%v records was deleted from mongoDB", deletedCount)
+	return deletedCount, nil
+}
+
+//limitSessions enforces session limit before refresh token is inserted.
+//Rotated token replaces the previous token of it`s session, so it`s own session is not counted.
+//Counting alone does not serialize transactions inserting different tokens, so the lock document of the user is written first:
+//concurrent logins of the user conflict on it and are retried by the driver one after another.
+func (t *TokenRepository) limitSessions(ctx context.Context, coll *mongo.Collection, refreshToken *entity.RefreshToken, event entity.AuditEvent) error {
+	if t.limit.PerUser > 0 {
+		if err := t.lock(ctx, "user:"+string(refreshToken.UserID)); err != nil {
+			return err
+		}
+	}
+	if t.limit.PerClient > 0 && refreshToken.ClientID != "" {
+		if err := t.lock(ctx, "client:"+string(refreshToken.UserID)+":"+refreshToken.ClientID); err != nil {
+			return err
+		}
+	}
+
+	active := bson.M{
+		"user_id":    refreshToken.UserID,
+		"used":       false,
+		"expires_at": bson.M{"$gt": refreshToken.RotatedAt},
+		"session_id": bson.M{"$ne": refreshToken.SessionID},
+	}
+	if t.limit.PerUser > 0 {
+		if err := t.limitActive(ctx, coll, active, t.limit.PerUser, event); err != nil {
+			return err
+		}
+	}
+	if t.limit.PerClient > 0 && refreshToken.ClientID != "" {
+		active["client_id"] = refreshToken.ClientID
+		if err := t.limitActive(ctx, coll, active, t.limit.PerClient, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//limitActive makes room for one more session among the ones matching filter or reports entity.ErrSessionLimit, depending on policy.
+//Eviction of every session is recorded as revocation by the actor of the issue event.
+func (t *TokenRepository) limitActive(ctx context.Context, coll *mongo.Collection, filter bson.M, limit int, event entity.AuditEvent) error {
+	count, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count < int64(limit) {
+		return nil
+	}
+	if t.limit.Policy != entity.SessionLimitEvict {
+		log.Printf("Limit of %v active sessions is reached", limit)
+		return entity.ErrSessionLimit
+	}
+
+	opts := options.Find().SetSort(bson.M{"rotated_at": 1}).SetLimit(count - int64(limit) + 1)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	evicted := []entity.RefreshToken{}
+	if err := cursor.All(ctx, &evicted); err != nil {
+		return err
+	}
+	for _, refreshToken := range evicted {
+		sessionID := refreshToken.SessionID
+		if sessionID == "" {
+			sessionID = refreshToken.UUID
+		}
+		eviction := event
+		eviction.ID = event.ID + "/" + sessionID
+		eviction.Type = entity.AuditRevoke
+		eviction.UserID = refreshToken.UserID
+		eviction.ClientID = refreshToken.ClientID
+		eviction.SessionID = sessionID
+		if _, err := t.deleteRecorded(ctx, coll, sessionFilter(refreshToken.UserID, sessionID), eviction); err != nil {
+			return err
+		}
+		log.Printf("Session %s of user with id=%v was evicted", sessionID, refreshToken.UserID)
+	}
+	return nil
+}
+
+//lock writes lock document with the key within the transaction,
+//any other transaction writing it before this one commits fails with a transient write conflict.
+func (t *TokenRepository) lock(ctx context.Context, key string) error {
+	cfg := config.New()
+	_, err := t.cl.Database(cfg.DbName).Collection(t.locks).UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$inc": bson.M{"inserts": 1}}, options.Update().SetUpsert(true))
+	return err
+}
+
+//deleteRecorded deletes refresh tokens matching filter and records the event with number of the deleted tokens.
+//Nothing is recorded if there were no such tokens.
+func (t *TokenRepository) deleteRecorded(ctx context.Context, coll *mongo.Collection, filter bson.M, event entity.AuditEvent) (*mongo.DeleteResult, error) {
+	result, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result.DeletedCount == 0 {
+		return result, nil
+	}
+	event.Count = result.DeletedCount
+	return result, t.record(ctx, event)
+}
+
+//record inserts audit event within the transaction of the change it describes.
+func (t *TokenRepository) record(ctx context.Context, event entity.AuditEvent) error {
+	cfg := config.New()
+	_, err := t.cl.Database(cfg.DbName).Collection(t.audit).InsertOne(ctx, &event)
+	return err
+}
+
+//sessionFilter matches all refresh tokens of particular session of the user.
+//Tokens issued before sessions were recorded form a session identified by their own id.
+func sessionFilter(userID entity.UserID, sessionID string) bson.M {
+	return bson.M{
+		"user_id": userID,
+		"$or":     bson.A{bson.M{"session_id": sessionID}, bson.M{"_id": sessionID, "session_id": bson.M{"$exists": false}}},
+	}
+}
+
+//tokenFilter converts entity.TokenFilter into mongoDB filter.
+//Tokens stored before sessions were introduced are their own family and have no issuance time.
+func tokenFilter(filter entity.TokenFilter) bson.M {
+	conditions := bson.A{}
+	if filter.UserID != "" {
+		conditions = append(conditions, bson.M{"user_id": filter.UserID})
+	}
+	if filter.ClientID != "" {
+		conditions = append(conditions, bson.M{"client_id": filter.ClientID})
+	}
+	if filter.SessionID != "" {
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"session_id": filter.SessionID},
+			bson.M{"_id": filter.SessionID, "session_id": bson.M{"$exists": false}},
+		}})
+	}
+	if !filter.IssuedAfter.IsZero() {
+		conditions = append(conditions, bson.M{"rotated_at": bson.M{"$gt": filter.IssuedAfter.Unix()}})
+	}
+	if !filter.IssuedBefore.IsZero() {
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"rotated_at": bson.M{"$lt": filter.IssuedBefore.Unix()}},
+			bson.M{"rotated_at": bson.M{"$exists": false}},
+		}})
+	}
+	switch filter.Status {
+	case entity.TokenStatusActive:
+		conditions = append(conditions, bson.M{"used": false, "expires_at": bson.M{"$gt": filter.Now.Unix()}})
+	case entity.TokenStatusUsed:
+		conditions = append(conditions, bson.M{"used": true})
+	case entity.TokenStatusExpired:
+		conditions = append(conditions, bson.M{"used": false, "expires_at": bson.M{"$lte": filter.Now.Unix()}})
+	}
+	if len(conditions) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": conditions}
+}
+
+//findRefreshToken looks up refresh token by id and reports whether it is missing or already used.
+func findRefreshToken(ctx context.Context, coll *mongo.Collection, refreshTokenUUID string) (*entity.RefreshToken, error) {
+	refreshToken := &entity.RefreshToken{}
+	err := coll.FindOne(ctx, bson.M{"_id": refreshTokenUUID}).Decode(refreshToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, entity.ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken.Used {
+		return nil, entity.ErrTokenUsed
+	}
+	return refreshToken, nil
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes domain errors and errors of the context through.
+func storageError(err error) error {
+	for _, domainErr := range []error{entity.ErrTokenNotFound, entity.ErrTokenUsed, entity.ErrSessionNotFound, entity.ErrSessionLimit, entity.ErrOverloaded, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err, domainErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
 }
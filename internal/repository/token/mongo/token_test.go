@@ -0,0 +1,128 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//plainHasher stores refresh tokens as is, tests do not need bcrypt.
+type plainHasher struct{}
+
+func (plainHasher) Hash(ctx context.Context, s string) (string, error) {
+	return s, nil
+}
+
+//connect returns client of replica set at MONGO_TEST_URI, the test is skipped if it is not set.
+func connect(t *testing.T) *mongodriver.Client {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx := context.Background()
+	cl, err := mongodriver.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cl.Disconnect(ctx) })
+	return cl
+}
+
+func TestInsertSessionLimitConcurrent(t *testing.T) {
+	cl := connect(t)
+	const (
+		logins    = 20
+		perUser   = 3
+		perClient = 2
+	)
+	for _, policy := range []string{entity.SessionLimitReject, entity.SessionLimitEvict} {
+		t.Run(policy, func(t *testing.T) {
+			ctx := context.Background()
+			db := cl.Database(config.New().DbName)
+			suffix := uuid.New().String()
+			repo := NewTokenRepository(cl, "tokens_"+suffix, "audit_"+suffix, "locks_"+suffix,
+				entity.SessionLimit{PerUser: perUser, PerClient: perClient, Policy: policy}, plainHasher{})
+			//Collections can not be created implicitly inside transactions by older servers.
+			for _, coll := range []string{repo.collection, repo.audit, repo.locks} {
+				if _, err := db.Collection(coll).InsertOne(ctx, bson.M{"_id": "init"}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := db.Collection(coll).DeleteOne(ctx, bson.M{"_id": "init"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Cleanup(func() {
+				for _, coll := range []string{repo.collection, repo.audit, repo.locks} {
+					db.Collection(coll).Drop(ctx)
+				}
+			})
+
+			userID := entity.UserID(uuid.New().String())
+			now := time.Now().Unix()
+			errs := make([]error, logins)
+			var wg sync.WaitGroup
+			for i := 0; i < logins; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id := uuid.New().String()
+					tokenPair := &entity.TokenPair{RefreshToken: entity.RefreshToken{
+						UUID:      id,
+						UserID:    userID,
+						Token:     id,
+						ExpiresAt: now + 3600,
+						SessionID: id,
+						CreatedAt: now,
+						RotatedAt: now,
+					}}
+					//Half of the logins go through the client, so both limits are contended at once.
+					if i%2 == 0 {
+						tokenPair.RefreshToken.ClientID = "client"
+					}
+					errs[i] = repo.Insert(ctx, tokenPair, entity.AuditEvent{ID: id, Type: entity.AuditIssue})
+				}(i)
+			}
+			wg.Wait()
+
+			inserted := 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					inserted++
+				case policy == entity.SessionLimitReject && errors.Is(err, entity.ErrSessionLimit):
+				default:
+					t.Errorf("got error %v", err)
+				}
+			}
+			if policy == entity.SessionLimitEvict && inserted != logins {
+				t.Errorf("%d of %d logins succeeded, want all of them", inserted, logins)
+			}
+
+			coll := db.Collection(repo.collection)
+			sessions, err := coll.CountDocuments(ctx, bson.M{"user_id": userID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sessions != perUser {
+				t.Errorf("got %d active sessions of the user, want %d", sessions, perUser)
+			}
+			clientSessions, err := coll.CountDocuments(ctx, bson.M{"user_id": userID, "client_id": "client"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientSessions > perClient {
+				t.Errorf("got %d active sessions of the user with the client, want at most %d", clientSessions, perClient)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//UserRepository is an user entity related abstraction for interacting with mongoDB.
+type UserRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewUserRepository returns a new UserRepository.
+func NewUserRepository(cl *mongo.Client, coll string) *UserRepository {
+	return &UserRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//Create inserts user into mongoDB.
+//It returns entity.ErrUserExists if user with the same id is already registered.
+func (u *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	cfg := config.New()
+	log.Printf("Inserting user with id=%v into mongoDB. Database name: %s, Collection: %s", user.ID, cfg.DbName, u.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := u.cl.Database(cfg.DbName).Collection(u.collection).InsertOne(sessCtx, user); err != nil {
+			if isDuplicateKeyError(err) {
+				return nil, entity.ErrUserExists
+			}
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	session, err := u.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+	log.Println("User was successfully stored in mongoDB")
+	return nil
+}
+
+//Get returns user by given id.
+//It returns entity.ErrUserNotFound if there is no such user.
+func (u *UserRepository) Get(ctx context.Context, userID entity.UserID) (*entity.User, error) {
+	cfg := config.New()
+	log.Printf("Searching user with id=%v in MongoDB. Database name: %s, Collection: %s", userID, cfg.DbName, u.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		user := &entity.User{}
+		err := u.cl.Database(cfg.DbName).Collection(u.collection).FindOne(sessCtx, bson.M{"_id": userID}).Decode(user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, entity.ErrUserNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	session, err := u.cl.StartSession()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, storageError(err)
+	}
+	return result.(*entity.User), nil
+}
+
+//UpdatePassword sets password hash of particular user.
+//It returns entity.ErrUserNotFound if there is no such user.
+func (u *UserRepository) UpdatePassword(ctx context.Context, userID entity.UserID, passwordHash string) error {
+	cfg := config.New()
+	log.Printf("Updating password of user with id=%v in MongoDB. Database name: %s, Collection: %s", userID, cfg.DbName, u.collection)
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		update := bson.M{"$set": bson.M{"password_hash": passwordHash, "updated_at": time.Now().Unix()}}
+		result, err := u.cl.Database(cfg.DbName).Collection(u.collection).UpdateOne(sessCtx, bson.M{"_id": userID}, update)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	session, err := u.cl.StartSession()
+	if err != nil {
+		return storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return storageError(err)
+	}
+
+	if result.(*mongo.UpdateResult).MatchedCount == 0 {
+		log.Println("There is no such user in MongoDB")
+		return entity.ErrUserNotFound
+	}
+	log.Println("Password has been successfully updated")
+	return nil
+}
+
+//isDuplicateKeyError checks whether err is caused by unique index violation.
+func isDuplicateKeyError(err error) bool {
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes domain errors and errors of the context through.
+func storageError(err error) error {
+	for _, domainErr := range []error{entity.ErrUserNotFound, entity.ErrUserExists, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err, domainErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"example.com/auth-service-go/config"
+	"example.com/auth-service-go/internal/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//WatermarkRepository keeps not before watermarks in mongoDB, so they take effect on all instances of the service at once.
+type WatermarkRepository struct {
+	cl         *mongo.Client
+	collection string
+}
+
+//NewWatermarkRepository returns a new WatermarkRepository.
+func NewWatermarkRepository(cl *mongo.Client, coll string) *WatermarkRepository {
+	return &WatermarkRepository{
+		cl:         cl,
+		collection: coll,
+	}
+}
+
+//NotBefore returns the latest of the global watermark and the watermark of the user.
+//Watermarks only move forward, so they are read without a transaction.
+func (r *WatermarkRepository) NotBefore(ctx context.Context, userID entity.UserID) (int64, error) {
+	cfg := config.New()
+
+	keys := bson.A{entity.GlobalWatermark}
+	if userID != "" {
+		keys = append(keys, userID.String())
+	}
+
+	coll := r.cl.Database(cfg.DbName).Collection(r.collection)
+	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	if err != nil {
+		log.Println(err.Error())
+		return 0, storageError(err)
+	}
+	var watermarks []entity.Watermark
+	if err := cursor.All(ctx, &watermarks); err != nil {
+		log.Println(err.Error())
+		return 0, storageError(err)
+	}
+	var notBefore int64
+	for _, w := range watermarks {
+		if w.NotBefore > notBefore {
+			notBefore = w.NotBefore
+		}
+	}
+	return notBefore, nil
+}
+
+//SetNotBefore moves the watermark of the user, or the global watermark if user id is empty, forward to given time.
+func (r *WatermarkRepository) SetNotBefore(ctx context.Context, userID entity.UserID, notBefore int64) (int64, error) {
+	cfg := config.New()
+	log.Printf("Setting not before watermark of user %q to %d", userID, notBefore)
+
+	key := entity.GlobalWatermark
+	if userID != "" {
+		key = userID.String()
+	}
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		coll := r.cl.Database(cfg.DbName).Collection(r.collection)
+		watermark := entity.Watermark{}
+		err := coll.FindOneAndUpdate(sessCtx, bson.M{"_id": key},
+			bson.M{"$max": bson.M{"not_before": notBefore}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&watermark)
+		if err != nil {
+			return nil, err
+		}
+		return watermark.NotBefore, nil
+	}
+
+	session, err := r.cl.StartSession()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		log.Println(err.Error())
+		return 0, storageError(err)
+	}
+	return result.(int64), nil
+}
+
+//storageError wraps driver errors into entity.ErrStorageUnavailable and passes errors of the context through.
+func storageError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", entity.ErrStorageUnavailable, err.Error())
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//Page sizes of token search.
+const (
+	DefaultTokensPageSize = 50
+	MaxTokensPageSize     = 500
+)
+
+//TokensPage is a page of refresh tokens found by FindTokens.
+type TokensPage struct {
+	Tokens []entity.RefreshToken
+	//NextOffset is an offset of the next page, it is zero if this page is the last one.
+	NextOffset int
+}
+
+//FindTokens returns page of refresh tokens matching the filter, the most recently issued first.
+//Limit of zero requests the default page size. Caller must have admin scope.
+func (s *AuthService) FindTokens(ctx context.Context, creds Credentials, filter entity.TokenFilter, offset, limit int) (*TokensPage, error) {
+	if offset < 0 {
+		return nil, &entity.ArgumentError{Message: "Offset must not be negative"}
+	}
+	if limit == 0 {
+		limit = DefaultTokensPageSize
+	}
+	if limit < 0 || limit > MaxTokensPageSize {
+		return nil, &entity.ArgumentError{Message: "Limit must be between 1 and 500"}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.authorizeAdmin(ctx, creds); err != nil {
+		return nil, err
+	}
+
+	filter.Now = s.now()
+	//One more token tells whether there is a next page.
+	refreshTokens, err := s.repo.FindRefreshTokens(ctx, filter, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &TokensPage{Tokens: refreshTokens}
+	if len(refreshTokens) > limit {
+		page.Tokens = refreshTokens[:limit]
+		page.NextOffset = offset + limit
+	}
+	return page, nil
+}
+
+//RevokeTokens deletes all refresh tokens matching the filter and returns their number.
+//Filter must select something, so that all tokens are never revoked by mistake. Caller must have admin scope.
+func (s *AuthService) RevokeTokens(ctx context.Context, creds Credentials, filter entity.TokenFilter) (int64, error) {
+	if filter.IsEmpty() {
+		return 0, &entity.ArgumentError{Message: "At least one criterion is required"}
+	}
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	principal, err := s.authorizeAdmin(ctx, creds)
+	if err != nil {
+		return 0, err
+	}
+
+	filter.Now = s.now()
+	event := s.auditEvent(ctx, entity.AuditBulkRevoke, principal.UserID)
+	event.UserID = filter.UserID
+	event.ClientID = filter.ClientID
+	event.SessionID = filter.SessionID
+	recorded := entity.AuditTokenFilter{Status: filter.Status}
+	if !filter.IssuedAfter.IsZero() {
+		recorded.IssuedAfter = filter.IssuedAfter.Unix()
+	}
+	if !filter.IssuedBefore.IsZero() {
+		recorded.IssuedBefore = filter.IssuedBefore.Unix()
+	}
+	if recorded != (entity.AuditTokenFilter{}) {
+		event.Filter = &recorded
+	}
+	deleted, err := s.repo.DeleteRefreshTokens(ctx, filter, event)
+	return deleted, s.recordFailure(ctx, event, err)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//Page sizes of audit event search.
+const (
+	DefaultAuditPageSize = 100
+	MaxAuditPageSize     = 1000
+)
+
+//AuditPage is a page of audit events found by FindAuditEvents.
+type AuditPage struct {
+	Events []entity.AuditEvent
+	//NextOffset is an offset of the next page, it is zero if this page is the last one.
+	NextOffset int
+}
+
+//FindAuditEvents returns page of audit events matching the filter in order of time.
+//Limit of zero requests the default page size. Caller must have admin scope.
+func (s *AuthService) FindAuditEvents(ctx context.Context, creds Credentials, filter entity.AuditFilter, offset, limit int) (*AuditPage, error) {
+	if offset < 0 {
+		return nil, &entity.ArgumentError{Message: "Offset must not be negative"}
+	}
+	if limit == 0 {
+		limit = DefaultAuditPageSize
+	}
+	if limit < 0 || limit > MaxAuditPageSize {
+		return nil, &entity.ArgumentError{Message: "Limit must be between 1 and 1000"}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.authorizeAdmin(ctx, creds); err != nil {
+		return nil, err
+	}
+
+	//One more event tells whether there is a next page.
+	events, err := s.audit.FindAuditEvents(ctx, filter, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextOffset = offset + limit
+	}
+	return page, nil
+}
+
+//ExportAuditEvents calls fn for every audit event matching the filter in order of time, until fn fails.
+//Events are read page by page, so export of the whole trail does not hold it in memory. Caller must have admin scope.
+func (s *AuthService) ExportAuditEvents(ctx context.Context, creds Credentials, filter entity.AuditFilter, fn func(entity.AuditEvent) error) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.authorizeAdmin(ctx, creds); err != nil {
+		return err
+	}
+
+	for offset := 0; ; offset += MaxAuditPageSize {
+		events, err := s.audit.FindAuditEvents(ctx, filter, offset, MaxAuditPageSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		if len(events) < MaxAuditPageSize {
+			return nil
+		}
+	}
+}
+
+//auditEvent returns successful audit event of given type caused by the actor, device is taken from ctx.
+func (s *AuthService) auditEvent(ctx context.Context, eventType string, actor entity.UserID) entity.AuditEvent {
+	device := DeviceFromContext(ctx)
+	return entity.AuditEvent{
+		ID:        s.newID(),
+		Type:      eventType,
+		Time:      s.now().Unix(),
+		Actor:     actor,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+		Result:    entity.AuditResultSuccess,
+	}
+}
+
+//recordFailure records the event failed with err and returns err. Successful events are recorded by token repository.
+//Presenting already used refresh token is recorded as reuse whatever operation it was presented for.
+//Failures of the storage itself are not recorded.
+func (s *AuthService) recordFailure(ctx context.Context, event entity.AuditEvent, err error) error {
+	if err == nil || errors.Is(err, entity.ErrStorageUnavailable) {
+		return err
+	}
+	if errors.Is(err, entity.ErrTokenUsed) {
+		event.Type = entity.AuditReuseDetected
+	}
+	if auditErr := s.audit.Record(ctx, event.Failed(err)); auditErr != nil {
+		log.Printf("Error recording %s audit event: %s", event.Type, auditErr.Error())
+	}
+	return err
+}
+
+//record records successful event of operation which does not change refresh tokens, so token repository does not record it.
+//Operation has already taken effect, so failure to record it is only logged.
+func (s *AuthService) record(ctx context.Context, event entity.AuditEvent) {
+	if err := s.audit.Record(ctx, event); err != nil {
+		log.Printf("Error recording %s audit event: %s", event.Type, err.Error())
+	}
+}
@@ -0,0 +1,482 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+//Clock returns current time.
+type Clock func() time.Time
+
+//Hasher generates bcrypt hashes of secrets and runs other slow hashing, e.g. of passwords and secret comparison,
+//so all of it can be limited together.
+type Hasher interface {
+	Hash(context.Context, string) (string, error)
+	Do(context.Context, func() error) error
+}
+
+//inlineHasher hashes in goroutine of the caller.
+type inlineHasher struct{}
+
+//Hash implements Hasher.
+func (inlineHasher) Hash(ctx context.Context, secret string) (string, error) {
+	return entity.GenerateHash(secret, entity.DefaultHashCost)
+}
+
+//Do implements Hasher.
+func (inlineHasher) Do(ctx context.Context, fn func() error) error {
+	return fn()
+}
+
+//IDGenerator returns a new unique id of refresh token.
+type IDGenerator func() string
+
+//TokenPair is a pair of tokens in the form handed out to clients.
+type TokenPair struct {
+	AccessToken string
+	//RefreshToken is base64 encoded jwt refresh token or opaque refresh token.
+	RefreshToken          string
+	AccessTokenExpiresAt  int64
+	RefreshTokenExpiresAt int64
+	//Scopes are scopes granted to the tokens.
+	Scopes []string
+	//IDToken is an OpenID Connect ID token, it is empty unless openid scope was granted.
+	IDToken string
+}
+
+//AuthService implements issuing, refreshing, revoking and validating of tokens independently of transport.
+type AuthService struct {
+	repo          repository.Token
+	users         repository.User
+	clients       repository.Client
+	codes         repository.AuthorizationCode
+	devices       repository.DeviceCode
+	watermarks    repository.Watermark
+	audit         repository.Audit
+	passwords     *UserPasswords
+	authenticator Authenticator
+	policy        *ScopePolicy
+	hasher        Hasher
+	//refreshTokenFormat is a format of issued refresh tokens, refresh tokens of both formats are accepted.
+	refreshTokenFormat string
+	now                Clock
+	newID              IDGenerator
+	//issuer is a base URL of the service, client assertions must be addressed to it or to it`s token endpoint.
+	issuer string
+	//idTokenKey signs OpenID Connect ID tokens.
+	idTokenKey *entity.SigningKey
+}
+
+//Option configures AuthService.
+type Option func(*AuthService)
+
+//WithClock sets clock used for issuing tokens.
+func WithClock(clock Clock) Option {
+	return func(s *AuthService) {
+		s.now = clock
+	}
+}
+
+//WithHasher sets hasher of client secrets and passwords.
+func WithHasher(hasher Hasher) Option {
+	return func(s *AuthService) {
+		s.hasher = hasher
+	}
+}
+
+//WithRefreshTokenFormat sets format of issued refresh tokens, either entity.RefreshTokenJWT or entity.RefreshTokenOpaque.
+func WithRefreshTokenFormat(format string) Option {
+	return func(s *AuthService) {
+		s.refreshTokenFormat = format
+	}
+}
+
+//WithIDGenerator sets generator of refresh token ids.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(s *AuthService) {
+		s.newID = gen
+	}
+}
+
+//WithIssuer sets base URL of the service.
+func WithIssuer(issuer string) Option {
+	return func(s *AuthService) {
+		s.issuer = issuer
+	}
+}
+
+//WithIDTokenKey sets key OpenID Connect ID tokens are signed with.
+func WithIDTokenKey(key *entity.SigningKey) Option {
+	return func(s *AuthService) {
+		s.idTokenKey = key
+	}
+}
+
+//WithScopePolicy sets policy deciding which scopes may be granted to tokens of users.
+func WithScopePolicy(policy *ScopePolicy) Option {
+	return func(s *AuthService) {
+		s.policy = policy
+	}
+}
+
+//NewAuthService returns a new AuthService.
+//Authenticator is consulted before issuing and revoking tokens on behalf of the user.
+func NewAuthService(repo repository.Token, users repository.User, clients repository.Client, codes repository.AuthorizationCode, devices repository.DeviceCode, watermarks repository.Watermark, audit repository.Audit, authenticator Authenticator, opts ...Option) *AuthService {
+	s := &AuthService{
+		repo:               repo,
+		users:              users,
+		clients:            clients,
+		codes:              codes,
+		devices:            devices,
+		watermarks:         watermarks,
+		audit:              audit,
+		authenticator:      authenticator,
+		policy:             &ScopePolicy{},
+		refreshTokenFormat: entity.RefreshTokenJWT,
+		hasher:             inlineHasher{},
+		now:                time.Now,
+		newID:              func() string { return uuid.New().String() },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.passwords = NewUserPasswords(users, s.hasher)
+	return s
+}
+
+//Issue authenticates the caller and creates a new pair of tokens for the user.
+//Caller may only obtain tokens for itself unless it has admin scope.
+//Requested scopes are narrowed to the ones allowed for the user, nil requests all of them.
+func (s *AuthService) Issue(ctx context.Context, creds Credentials, userID entity.UserID, scopes []string) (*TokenPair, error) {
+	if userID == "" {
+		return nil, &entity.ArgumentError{Message: "User id is empty"}
+	}
+	principal, err := s.authorize(ctx, creds, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueForUser(ctx, principal.UserID, userID, scopes)
+}
+
+//issueForUser creates a new pair of tokens issued directly to the user with requested scopes narrowed by the policy.
+func (s *AuthService) issueForUser(ctx context.Context, actor, userID entity.UserID, scopes []string) (*TokenPair, error) {
+	allowed, roles, err := s.allowedScopes(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, actor, entity.TokenParams{
+		UserID: userID,
+		Scopes: grantScopes(scopes, allowed),
+		Roles:  roles,
+	})
+}
+
+//allowedScopes returns scopes which may be granted to tokens of the user and roles of the user.
+//Scopes allowed by roles of the user are narrowed to scopes of the client if it is not nil.
+//Users without account in this service have no roles.
+func (s *AuthService) allowedScopes(ctx context.Context, userID entity.UserID, client *entity.Client) ([]string, []string, error) {
+	var roles []string
+	user, err := s.users.Get(ctx, userID)
+	switch {
+	case err == nil:
+		roles = user.Roles
+	case !errors.Is(err, entity.ErrUserNotFound):
+		return nil, nil, err
+	}
+	allowed := s.policy.UserScopes(roles)
+	if client != nil {
+		allowed = entity.IntersectScopes(allowed, client.Scopes)
+	}
+	return allowed, roles, nil
+}
+
+//issue creates a new pair of tokens described by params on behalf of the actor and stores refresh token.
+//Refresh token id and issue time are set by the service, device the pair is issued to is taken from ctx.
+func (s *AuthService) issue(ctx context.Context, actor entity.UserID, params entity.TokenParams) (*TokenPair, error) {
+	issuedAt, err := s.issueTime(ctx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+	params.IssuedAt = issuedAt
+	params.RefreshTokenUUID = s.newID()
+	params.RefreshTokenFormat = s.refreshTokenFormat
+	device := DeviceFromContext(ctx)
+	params.UserAgent = device.UserAgent
+	params.IP = device.IP
+	tokenPair, err := entity.NewTokenPair(params)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken := tokenPair.RefreshToken.Token
+	event := s.auditEvent(ctx, entity.AuditIssue, actor)
+	event.UserID = params.UserID
+	event.ClientID = params.ClientID
+	event.TokenIDs = []string{params.RefreshTokenUUID}
+	if err := s.repo.Insert(ctx, tokenPair, event); err != nil {
+		return nil, s.recordFailure(ctx, event, err)
+	}
+	//Convert jwt refresh token into base64 string before sending it to the user, opaque one is sent as is.
+	if !entity.IsOpaqueRefreshToken(refreshToken) {
+		refreshToken = entity.EncodeToken64(refreshToken)
+	}
+	return &TokenPair{
+		AccessToken:           tokenPair.AccessToken.Token,
+		RefreshToken:          refreshToken,
+		AccessTokenExpiresAt:  tokenPair.AccessToken.ExpiresAt,
+		RefreshTokenExpiresAt: tokenPair.RefreshToken.ExpiresAt,
+		Scopes:                params.Scopes,
+		IDToken:               tokenPair.IDToken,
+	}, nil
+}
+
+//Refresh marks refresh token as used and issues a new pair of tokens.
+//Access token must have been issued together with the refresh token.
+//Scopes may narrow scopes of the refresh token, nil keeps them.
+func (s *AuthService) Refresh(ctx context.Context, accessToken, refreshToken string, scopes []string) (*TokenPair, error) {
+	if accessToken == "" {
+		return nil, &entity.ArgumentError{Message: "Access token is empty"}
+	}
+	claimsRefreshToken, err := s.parseRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	claimsAccessToken, err := entity.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	//Check bind between access and refresh token.
+	if claimsAccessToken.Refresh_uuid != claimsRefreshToken.UUID {
+		return nil, entity.ErrTokenMismatch
+	}
+	var client *entity.Client
+	if claimsRefreshToken.Client_id != "" {
+		client, err = s.clients.Get(ctx, claimsRefreshToken.Client_id)
+		if errors.Is(err, entity.ErrClientNotFound) {
+			return nil, fmt.Errorf("%w: client of the token is not registered", entity.ErrInvalidToken)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.rotate(ctx, claimsRefreshToken, client, scopes)
+}
+
+//RefreshGrant marks refresh token as used and issues a new pair of tokens as OAuth 2.0 refresh_token grant does.
+//Unlike Refresh it does not require access token issued together with the refresh token.
+//Client is an authenticated client or nil if client did not authenticate.
+//Tokens issued to a client may only be refreshed by the same client.
+//Scopes may narrow scopes of the refresh token, nil keeps them.
+func (s *AuthService) RefreshGrant(ctx context.Context, client *entity.Client, refreshToken string, scopes []string) (*TokenPair, error) {
+	claimsRefreshToken, err := s.parseRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		if claimsRefreshToken.Client_id != "" {
+			return nil, fmt.Errorf("%w: token was issued to a client", entity.ErrUnauthenticated)
+		}
+	} else {
+		if !client.AllowsGrant(entity.GrantRefreshToken) {
+			return nil, entity.ErrForbidden
+		}
+		if claimsRefreshToken.Client_id != client.ID {
+			return nil, fmt.Errorf("%w: token was not issued to the client", entity.ErrInvalidToken)
+		}
+	}
+	return s.rotate(ctx, claimsRefreshToken, client, scopes)
+}
+
+//ClientCredentialsGrant issues access token for authenticated client as OAuth 2.0 client_credentials grant does.
+//Requested scopes are narrowed to scopes of the client, nil requests all of them.
+//Refresh token is not issued, RefreshToken of the result is empty.
+func (s *AuthService) ClientCredentialsGrant(ctx context.Context, client *entity.Client, scopes []string) (*TokenPair, error) {
+	event := s.auditEvent(ctx, entity.AuditIssue, "")
+	event.ClientID = client.ID
+	if !client.AllowsGrant(entity.GrantClientCredentials) {
+		return nil, s.recordFailure(ctx, event, entity.ErrForbidden)
+	}
+	params := client.TokenParams("", s.now())
+	params.Scopes = grantScopes(scopes, client.Scopes)
+	accessToken, err := entity.NewAccessToken(params)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, event)
+	return &TokenPair{
+		AccessToken:          accessToken.Token,
+		AccessTokenExpiresAt: accessToken.ExpiresAt,
+		Scopes:               params.Scopes,
+	}, nil
+}
+
+//rotate marks refresh token as used and issues a new pair of tokens for it`s user.
+//Client is the client token was issued to, it is nil for tokens issued directly to users.
+//New pair gets requested scopes, or scopes of the refresh token if nil, narrowed to the ones still allowed.
+//Requesting scope the refresh token was not granted is reported as entity.ErrInvalidScope.
+func (s *AuthService) rotate(ctx context.Context, claimsRefreshToken *entity.CustomClaimsRefreshToken, client *entity.Client, scopes []string) (*TokenPair, error) {
+	userID, err := claimsUserID(claimsRefreshToken.User_id)
+	if err != nil {
+		return nil, err
+	}
+	granted := entity.ParseScope(claimsRefreshToken.Scope)
+	if scopes != nil {
+		if !entity.IsScopeSubset(scopes, granted) {
+			return nil, entity.ErrInvalidScope
+		}
+		granted = scopes
+	}
+	event := s.auditEvent(ctx, entity.AuditRotate, userID)
+	event.UserID = userID
+	event.ClientID = claimsRefreshToken.Client_id
+	event.TokenIDs = []string{claimsRefreshToken.UUID}
+	stored, err := s.repo.GetRefreshToken(ctx, claimsRefreshToken.UUID)
+	if err != nil {
+		return nil, s.recordFailure(ctx, event, err)
+	}
+	if err := s.repo.RefreshTokenSetIsUsed(ctx, claimsRefreshToken.UUID, event); err != nil {
+		return nil, s.recordFailure(ctx, event, err)
+	}
+	allowed, roles, err := s.allowedScopes(ctx, userID, client)
+	if err != nil {
+		return nil, err
+	}
+	params := entity.TokenParams{UserID: userID}
+	if client != nil {
+		params = client.TokenParams(userID, s.now())
+	}
+	params.Scopes = entity.IntersectScopes(granted, allowed)
+	params.Roles = roles
+	//New token continues the session of the rotated one.
+	params.SessionID = stored.UUID
+	params.SessionCreatedAt = s.now()
+	if stored.SessionID != "" {
+		params.SessionID = stored.SessionID
+		params.SessionCreatedAt = time.Unix(stored.CreatedAt, 0)
+	}
+	return s.issue(ctx, userID, params)
+}
+
+//Now returns current time of the service clock.
+func (s *AuthService) Now() time.Time {
+	return s.now()
+}
+
+//Issuer returns base URL of the service.
+func (s *AuthService) Issuer() string {
+	return s.issuer
+}
+
+//IDTokenKey returns key OpenID Connect ID tokens are signed with, it is nil if ID tokens are not issued.
+func (s *AuthService) IDTokenKey() *entity.SigningKey {
+	return s.idTokenKey
+}
+
+//Revoke deletes particular refresh token.
+//Caller may only revoke own tokens unless it has admin scope.
+func (s *AuthService) Revoke(ctx context.Context, creds Credentials, refreshToken string) error {
+	claimsRefreshToken, err := s.parseRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	userID, err := claimsUserID(claimsRefreshToken.User_id)
+	if err != nil {
+		return err
+	}
+	principal, err := s.authorize(ctx, creds, userID)
+	if err != nil {
+		return err
+	}
+
+	event := s.auditEvent(ctx, entity.AuditRevoke, principal.UserID)
+	event.UserID = userID
+	event.ClientID = claimsRefreshToken.Client_id
+	event.TokenIDs = []string{claimsRefreshToken.UUID}
+	if err := s.repo.CheckRefreshToken(ctx, claimsRefreshToken.UUID); err != nil {
+		return s.recordFailure(ctx, event, err)
+	}
+	return s.recordFailure(ctx, event, s.repo.DeleteRefreshToken(ctx, userID, claimsRefreshToken.UUID, event))
+}
+
+//RevokeAll deletes all refresh tokens of the user, it succeeds if the user has none.
+//Caller may only revoke own tokens unless it has admin scope.
+func (s *AuthService) RevokeAll(ctx context.Context, creds Credentials, userID entity.UserID) error {
+	if userID == "" {
+		return &entity.ArgumentError{Message: "User id is empty"}
+	}
+	principal, err := s.authorize(ctx, creds, userID)
+	if err != nil {
+		return err
+	}
+
+	event := s.auditEvent(ctx, entity.AuditRevoke, principal.UserID)
+	event.UserID = userID
+	return s.recordFailure(ctx, event, s.repo.DeleteUserRefreshTokens(ctx, userID, event))
+}
+
+//Validate checks access token and returns it`s claims.
+func (s *AuthService) Validate(ctx context.Context, accessToken string) (*entity.CustomClaimsAcessToken, error) {
+	if accessToken == "" {
+		return nil, &entity.ArgumentError{Message: "Access token is empty"}
+	}
+	return entity.ParseAccessToken(ctx, accessToken)
+}
+
+//authorize authenticates the caller and checks it may act on behalf of the user.
+func (s *AuthService) authorize(ctx context.Context, creds Credentials, userID entity.UserID) (*Principal, error) {
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.CanActFor(userID) {
+		return nil, entity.ErrForbidden
+	}
+	return principal, nil
+}
+
+//claimsUserID parses user id claim of token. Tokens with malformed user id are not valid.
+func claimsUserID(claim string) (entity.UserID, error) {
+	userID, err := entity.ParseUserID(claim)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", entity.ErrInvalidToken, err.Error())
+	}
+	return userID, nil
+}
+
+//parseRefreshToken decodes base64 jwt refresh token and returns it`s claims.
+//Opaque refresh token is verified against the stored one, which is returned in the form of claims.
+func (s *AuthService) parseRefreshToken(ctx context.Context, refreshToken string) (*entity.CustomClaimsRefreshToken, error) {
+	if refreshToken == "" {
+		return nil, &entity.ArgumentError{Message: "Refresh token is empty"}
+	}
+	if entity.IsOpaqueRefreshToken(refreshToken) {
+		id, err := entity.OpaqueRefreshTokenID(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := s.repo.GetRefreshToken(ctx, id)
+		if errors.Is(err, entity.ErrTokenUsed) {
+			//Holder of the used token is not known until it is verified, so reuse is recorded by token id only.
+			event := s.auditEvent(ctx, entity.AuditReuseDetected, "")
+			event.TokenIDs = []string{id}
+			return nil, s.recordFailure(ctx, event, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := stored.VerifyOpaque(ctx, refreshToken, s.now()); err != nil {
+			return nil, err
+		}
+		return stored.Claims(), nil
+	}
+	decoded, err := entity.DecodeToken64(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return entity.ParseRefreshToken(ctx, decoded)
+}
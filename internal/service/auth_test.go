@@ -0,0 +1,403 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+	"example.com/auth-service-go/internal/service/servicetest"
+)
+
+const (
+	adminKey = "admin key"
+	adminID  = entity.UserID("5d1c9c1e-3c4b-4f5e-9a77-1c2d3e4f5a6b")
+	userKey  = "user key"
+	userID   = entity.UserID("0b4f7d2e-9a3c-4e51-8f6d-2c7a1b9e0d34")
+	otherID  = entity.UserID("7f3e2d1c-0b9a-4876-9543-210fedcba987")
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("TOKEN_SECRET", "test token secret")
+	os.Exit(m.Run())
+}
+
+//fakeTokens is repository.Token keeping refresh tokens in memory and remembering the events it recorded.
+type fakeTokens struct {
+	mu     sync.Mutex
+	tokens map[string]entity.RefreshToken
+	events []entity.AuditEvent
+}
+
+func newFakeTokens() *fakeTokens {
+	return &fakeTokens{tokens: map[string]entity.RefreshToken{}}
+}
+
+func (f *fakeTokens) Insert(ctx context.Context, tokenPair *entity.TokenPair, event entity.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[tokenPair.RefreshToken.UUID] = tokenPair.RefreshToken
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeTokens) DeleteUserRefreshTokens(ctx context.Context, userID entity.UserID, event entity.AuditEvent) error {
+	f.delete(func(token entity.RefreshToken) bool { return token.UserID == userID }, event)
+	return nil
+}
+
+func (f *fakeTokens) DeleteClientRefreshTokens(ctx context.Context, clientID string, event entity.AuditEvent) error {
+	f.delete(func(token entity.RefreshToken) bool { return token.ClientID == clientID }, event)
+	return nil
+}
+
+func (f *fakeTokens) DeleteRefreshToken(ctx context.Context, userID entity.UserID, refreshTokenUUID string, event entity.AuditEvent) error {
+	if f.delete(func(token entity.RefreshToken) bool { return token.UUID == refreshTokenUUID && token.UserID == userID }, event) == 0 {
+		return entity.ErrTokenNotFound
+	}
+	return nil
+}
+
+func (f *fakeTokens) CheckRefreshToken(ctx context.Context, refreshTokenUUID string) error {
+	_, err := f.GetRefreshToken(ctx, refreshTokenUUID)
+	return err
+}
+
+func (f *fakeTokens) GetRefreshToken(ctx context.Context, refreshTokenUUID string) (*entity.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[refreshTokenUUID]
+	switch {
+	case !ok:
+		return nil, entity.ErrTokenNotFound
+	case token.Used:
+		return nil, entity.ErrTokenUsed
+	}
+	return &token, nil
+}
+
+func (f *fakeTokens) RefreshTokenSetIsUsed(ctx context.Context, refreshTokenUUID string, event entity.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[refreshTokenUUID]
+	switch {
+	case !ok:
+		return entity.ErrTokenNotFound
+	case token.Used:
+		return entity.ErrTokenUsed
+	}
+	token.Used = true
+	f.tokens[refreshTokenUUID] = token
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeTokens) UserRefreshTokens(ctx context.Context, userID entity.UserID) ([]entity.RefreshToken, error) {
+	return nil, errors.New("UserRefreshTokens is not expected")
+}
+
+func (f *fakeTokens) DeleteSession(ctx context.Context, userID entity.UserID, sessionID string, event entity.AuditEvent) error {
+	return errors.New("DeleteSession is not expected")
+}
+
+func (f *fakeTokens) FindRefreshTokens(ctx context.Context, filter entity.TokenFilter, offset, limit int) ([]entity.RefreshToken, error) {
+	return nil, errors.New("FindRefreshTokens is not expected")
+}
+
+func (f *fakeTokens) DeleteRefreshTokens(ctx context.Context, filter entity.TokenFilter, event entity.AuditEvent) (int64, error) {
+	return 0, errors.New("DeleteRefreshTokens is not expected")
+}
+
+//delete deletes tokens matching the filter, the event is remembered if there were any.
+func (f *fakeTokens) delete(match func(entity.RefreshToken) bool, event entity.AuditEvent) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deleted := 0
+	for id, token := range f.tokens {
+		if match(token) {
+			delete(f.tokens, id)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		f.events = append(f.events, event)
+	}
+	return deleted
+}
+
+//has reports whether refresh token with given id is stored and whether it is used.
+func (f *fakeTokens) has(id string) (stored, used bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[id]
+	return ok, token.Used
+}
+
+//sequence is a deterministic IDGenerator returning UUIDs numbered from one.
+func sequence() service.IDGenerator {
+	n := 0
+	return func() string {
+		n++
+		return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+	}
+}
+
+//serviceTest is AuthService backed by fakeTokens with clock standing still at now, which tests move explicitly.
+type serviceTest struct {
+	auth   *service.AuthService
+	tokens *fakeTokens
+	repos  *servicetest.Repositories
+	now    time.Time
+}
+
+func newServiceTest(t *testing.T) *serviceTest {
+	apiKeys, err := service.NewAPIKeyAuthenticator(adminKey + ":" + string(adminID) + ":" + service.ScopeAdmin + ";" + userKey + ":" + string(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Token expiry and issue time are checked against wall clock, so the fixed time is an hour before it.
+	st := &serviceTest{tokens: newFakeTokens(), repos: servicetest.New(), now: time.Now().Add(-time.Hour).Truncate(time.Second)}
+	r := st.repos
+	st.auth = service.NewAuthService(st.tokens, r.Users, r.Clients, r.Codes, r.Devices, r.Watermarks, r.Audit,
+		service.Authenticators{apiKeys, service.AccessTokenAuthenticator{}},
+		service.WithClock(func() time.Time { return st.now }), service.WithIDGenerator(sequence()))
+	return st
+}
+
+func as(key string) service.Credentials {
+	return service.Credentials{APIKey: key}
+}
+
+func wantErr(t *testing.T, got, want error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Fatalf("got error %v, want %v", got, want)
+	}
+}
+
+func TestIssue(t *testing.T) {
+	tests := []struct {
+		name   string
+		creds  service.Credentials
+		userID entity.UserID
+		err    error
+	}{
+		{"own tokens", as(userKey), userID, nil},
+		{"admin for another user", as(adminKey), userID, nil},
+		{"for another user", as(userKey), otherID, entity.ErrForbidden},
+		{"without credentials", service.Credentials{}, userID, entity.ErrUnauthenticated},
+		{"wrong key", as("wrong key"), userID, entity.ErrUnauthenticated},
+		{"empty user id", as(adminKey), "", entity.ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newServiceTest(t)
+			tokenPair, err := st.auth.Issue(context.Background(), tt.creds, tt.userID, nil)
+			wantErr(t, err, tt.err)
+			if tt.err != nil {
+				if len(st.tokens.tokens) != 0 {
+					t.Errorf("got %d stored tokens, want none", len(st.tokens.tokens))
+				}
+				return
+			}
+
+			const id = "00000000-0000-4000-8000-000000000001"
+			if stored, _ := st.tokens.has(id); !stored {
+				t.Fatalf("refresh token %s is not stored: %+v", id, st.tokens.tokens)
+			}
+			if want := st.now.Add(entity.RefreshTokenTTL).Unix(); tokenPair.RefreshTokenExpiresAt != want {
+				t.Errorf("got refresh token expiring at %d, want %d", tokenPair.RefreshTokenExpiresAt, want)
+			}
+			claims, err := st.auth.Validate(context.Background(), tokenPair.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.User_id != string(tt.userID) || claims.Refresh_uuid != id || claims.IssuedAt != st.now.Unix() {
+				t.Errorf("got claims %+v, want user %s, refresh token %s issued at %d", claims, tt.userID, id, st.now.Unix())
+			}
+			if len(st.tokens.events) != 1 || st.tokens.events[0].Type != entity.AuditIssue || st.tokens.events[0].Time != st.now.Unix() {
+				t.Errorf("got events %+v, want issue event at %d", st.tokens.events, st.now.Unix())
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	st := newServiceTest(t)
+	ctx := context.Background()
+	issued, err := st.auth.Issue(ctx, as(userKey), userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := st.auth.Issue(ctx, as(userKey), userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.auth.Refresh(ctx, "", issued.RefreshToken, nil)
+	wantErr(t, err, entity.ErrInvalidArgument)
+	_, err = st.auth.Refresh(ctx, other.AccessToken, issued.RefreshToken, nil)
+	wantErr(t, err, entity.ErrTokenMismatch)
+
+	st.now = st.now.Add(time.Minute)
+	refreshed, err := st.auth.Refresh(ctx, issued.AccessToken, issued.RefreshToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, used := st.tokens.has("00000000-0000-4000-8000-000000000001"); !used {
+		t.Error("rotated refresh token is not marked used")
+	}
+	claims, err := st.auth.Validate(ctx, refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Each issue takes an id for the refresh token and one for it`s audit event, rotation takes one more for the event.
+	if claims.Refresh_uuid != "00000000-0000-4000-8000-000000000006" || claims.IssuedAt != st.now.Unix() {
+		t.Errorf("got claims %+v of refreshed token", claims)
+	}
+
+	//Presenting the rotated token again is refused and recorded as reuse.
+	_, err = st.auth.Refresh(ctx, issued.AccessToken, issued.RefreshToken, nil)
+	wantErr(t, err, entity.ErrTokenUsed)
+	events := st.repos.Audit.Events()
+	if len(events) != 1 || events[0].Type != entity.AuditReuseDetected || events[0].Result != entity.ErrTokenUsed.Error() {
+		t.Errorf("got audit events %+v, want failed reuse detection", events)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	st := newServiceTest(t)
+	ctx := context.Background()
+	issued, err := st.auth.Issue(ctx, as(userKey), userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.auth.Issue(ctx, as(adminKey), otherID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, st.auth.Revoke(ctx, service.Credentials{}, issued.RefreshToken), entity.ErrUnauthenticated)
+	wantErr(t, st.auth.Revoke(ctx, as(userKey), ""), entity.ErrInvalidArgument)
+	wantErr(t, st.auth.Revoke(ctx, as(userKey), "token"), entity.ErrMalformedToken)
+	if err := st.auth.Revoke(ctx, as(userKey), issued.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := st.tokens.has("00000000-0000-4000-8000-000000000001"); stored {
+		t.Error("revoked refresh token is still stored")
+	}
+	wantErr(t, st.auth.Revoke(ctx, as(userKey), issued.RefreshToken), entity.ErrTokenNotFound)
+	if len(st.tokens.tokens) != 1 {
+		t.Errorf("got %d stored tokens, want only the token of the other user", len(st.tokens.tokens))
+	}
+}
+
+func TestRevokeAll(t *testing.T) {
+	st := newServiceTest(t)
+	ctx := context.Background()
+	for _, id := range []entity.UserID{userID, userID, otherID} {
+		if _, err := st.auth.Issue(ctx, as(adminKey), id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wantErr(t, st.auth.RevokeAll(ctx, as(userKey), otherID), entity.ErrForbidden)
+	wantErr(t, st.auth.RevokeAll(ctx, as(userKey), ""), entity.ErrInvalidArgument)
+	if err := st.auth.RevokeAll(ctx, as(userKey), userID); err != nil {
+		t.Fatal(err)
+	}
+	for id, token := range st.tokens.tokens {
+		if token.UserID == userID {
+			t.Errorf("refresh token %s of the user is still stored", id)
+		}
+	}
+	if len(st.tokens.tokens) != 1 {
+		t.Errorf("got %d stored tokens, want only the token of the other user", len(st.tokens.tokens))
+	}
+	//User without tokens is not an error.
+	if err := st.auth.RevokeAll(ctx, as(userKey), userID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	st := newServiceTest(t)
+	ctx := context.Background()
+	issued, err := st.auth.Issue(ctx, as(userKey), userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Clock of the service is moved back, so the token is expired by now.
+	st.now = st.now.Add(-entity.AccessTokenTTL - time.Minute)
+	expired, err := st.auth.Issue(ctx, as(userKey), userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", issued.AccessToken, nil},
+		{"empty", "", entity.ErrInvalidArgument},
+		{"malformed", "token", entity.ErrMalformedToken},
+		{"refresh token", issued.RefreshToken, entity.ErrMalformedToken},
+		{"expired", expired.AccessToken, entity.ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := st.auth.Validate(ctx, tt.token)
+			wantErr(t, err, tt.err)
+			if err == nil && claims.User_id != string(userID) {
+				t.Errorf("got claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestIssueAfterWatermark(t *testing.T) {
+	st := newServiceTest(t)
+	entity.UseWatermarks(st.repos.Watermarks)
+	defer entity.UseWatermarks(nil)
+	ctx := context.Background()
+	st.now = st.now.Add(300 * time.Millisecond)
+	if _, err := st.repos.Watermarks.SetNotBefore(ctx, userID, st.now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := st.auth.Issue(canceled, as(userKey), userID, nil)
+	wantErr(t, err, context.Canceled)
+
+	//Token issued in the second of the watermark would be revoked by it, so it starts in the next second.
+	issued, err := st.auth.Issue(ctx, as(userKey), userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := st.auth.Validate(ctx, issued.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := st.now.Unix() + 1; claims.IssuedAt != want {
+		t.Errorf("got token issued at %d, want %d", claims.IssuedAt, want)
+	}
+	if _, err := st.auth.Refresh(ctx, issued.AccessToken, issued.RefreshToken, nil); err != nil {
+		t.Errorf("refresh token issued after the watermark is not accepted: %v", err)
+	}
+
+	//Tokens of other users are issued at once.
+	issued, err = st.auth.Issue(ctx, as(adminKey), otherID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err = st.auth.Validate(ctx, issued.AccessToken); err != nil || claims.IssuedAt != st.now.Unix() {
+		t.Errorf("got claims %+v and error %v of token of other user, want it issued at %d", claims, err, st.now.Unix())
+	}
+}
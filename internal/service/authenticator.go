@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"example.com/auth-service-go/internal/entity"
+	"github.com/dgrijalva/jwt-go"
+)
+
+//ScopeAdmin allows caller to act on behalf of any user.
+const ScopeAdmin = "admin"
+
+//Credentials are credentials presented by the caller. Only some of the fields are set.
+type Credentials struct {
+	UserID   string
+	Password string
+	APIKey   string
+	//BearerToken is either access token issued by this service or assertion of upstream identity provider.
+	BearerToken string
+}
+
+//CredentialsFromHeaders extracts credentials from values of Authorization and X-API-Key headers.
+func CredentialsFromHeaders(authorization, apiKey string) Credentials {
+	creds := Credentials{APIKey: apiKey}
+	scheme, value := authorization, ""
+	if i := strings.IndexByte(authorization, ' '); i >= 0 {
+		scheme, value = authorization[:i], strings.TrimSpace(authorization[i+1:])
+	}
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		creds.BearerToken = value
+	case "apikey":
+		creds.APIKey = value
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			break
+		}
+		if i := strings.IndexByte(string(decoded), ':'); i >= 0 {
+			creds.UserID, creds.Password = string(decoded[:i]), string(decoded[i+1:])
+		}
+	}
+	return creds
+}
+
+//Principal is an authenticated caller.
+type Principal struct {
+	UserID entity.UserID
+	Scopes []string
+}
+
+//HasScope reports whether principal was granted the scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//CanActFor reports whether principal may obtain or revoke tokens of the user.
+func (p *Principal) CanActFor(userID entity.UserID) bool {
+	return p.UserID == userID || p.HasScope(ScopeAdmin)
+}
+
+//Authenticator verifies credentials of the caller.
+//It returns entity.ErrUnauthenticated if credentials are missing or not valid.
+type Authenticator interface {
+	Authenticate(context.Context, Credentials) (*Principal, error)
+}
+
+//AuthenticatorFunc is an adapter to allow the use of ordinary functions as authenticators.
+type AuthenticatorFunc func(context.Context, Credentials) (*Principal, error)
+
+//Authenticate calls f(ctx, creds).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	return f(ctx, creds)
+}
+
+//Authenticators tries each authenticator in order and returns the first principal.
+type Authenticators []Authenticator
+
+//Authenticate implements Authenticator.
+func (a Authenticators) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(ctx, creds)
+		if err == nil {
+			return principal, nil
+		}
+		if !errors.Is(err, entity.ErrUnauthenticated) {
+			return nil, err
+		}
+	}
+	return nil, entity.ErrUnauthenticated
+}
+
+//PasswordVerifier checks password of the user.
+//It returns entity.ErrUnauthenticated if there is no such user or password does not match.
+type PasswordVerifier interface {
+	VerifyPassword(ctx context.Context, userID entity.UserID, password string) error
+}
+
+//PasswordAuthenticator authenticates users by their password.
+type PasswordAuthenticator struct {
+	Verifier PasswordVerifier
+}
+
+//Authenticate implements Authenticator.
+func (a *PasswordAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	if creds.UserID == "" || creds.Password == "" {
+		return nil, entity.ErrUnauthenticated
+	}
+	userID, err := entity.ParseUserID(creds.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	if err := a.Verifier.VerifyPassword(ctx, userID, creds.Password); err != nil {
+		return nil, err
+	}
+	return &Principal{UserID: userID}, nil
+}
+
+//APIKeyAuthenticator authenticates callers by static API keys.
+type APIKeyAuthenticator struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+//NewAPIKeyAuthenticator returns APIKeyAuthenticator from keys specification.
+//Specification is a semicolon separated list of key:user_id:scope,scope entries, scopes are optional.
+//User ids must be GUIDs.
+func NewAPIKeyAuthenticator(spec string) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("API key entry %q is not valid", entry)
+		}
+		userID, err := entity.ParseUserID(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("API key entry for user %q is not valid: %s", parts[1], err.Error())
+		}
+		principal := Principal{UserID: userID}
+		if len(parts) == 3 && parts[2] != "" {
+			principal.Scopes = strings.Split(parts[2], ",")
+		}
+		a.keys = append(a.keys, apiKey{hash: sha256.Sum256([]byte(parts[0])), principal: principal})
+	}
+	return a, nil
+}
+
+//Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	if creds.APIKey == "" {
+		return nil, entity.ErrUnauthenticated
+	}
+	//Compare hashes in constant time to not leak keys through timing.
+	hash := sha256.Sum256([]byte(creds.APIKey))
+	for _, key := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], key.hash[:]) == 1 {
+			principal := key.principal
+			return &principal, nil
+		}
+	}
+	return nil, entity.ErrUnauthenticated
+}
+
+//AccessTokenAuthenticator authenticates callers by access tokens issued by this service.
+//Principal gets scopes granted to the token.
+type AccessTokenAuthenticator struct {
+	//Audience is an audience exchanged tokens must be issued for to authenticate callers,
+	//tokens exchanged for other services are rejected. Tokens without audience are always accepted.
+	Audience string
+}
+
+//Authenticate implements Authenticator.
+func (a AccessTokenAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	if creds.BearerToken == "" {
+		return nil, entity.ErrUnauthenticated
+	}
+	claims, err := entity.ParseAccessToken(ctx, creds.BearerToken)
+	if errors.Is(err, entity.ErrStorageUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	if claims.Audience != "" && claims.Audience != a.Audience {
+		return nil, fmt.Errorf("%w: token is issued for another audience", entity.ErrUnauthenticated)
+	}
+	userID, err := entity.ParseUserID(claims.User_id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	return &Principal{UserID: userID, Scopes: entity.ParseScope(claims.Scope)}, nil
+}
+
+//AssertionAuthenticator authenticates callers by JWT assertions of upstream identity provider signed with shared secret.
+//Assertion must have sub and exp claims and may have space separated scope claim.
+type AssertionAuthenticator struct {
+	Secret []byte
+}
+
+type assertionClaims struct {
+	Scope string `json:"scope"`
+	jwt.StandardClaims
+}
+
+//Authenticate implements Authenticator.
+func (a *AssertionAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	if creds.BearerToken == "" || len(a.Secret) == 0 {
+		return nil, entity.ErrUnauthenticated
+	}
+	claims := &assertionClaims{}
+	_, err := jwt.ParseWithClaims(creds.BearerToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
+		}
+		return a.Secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: assertion must have exp claim", entity.ErrUnauthenticated)
+	}
+	userID, err := entity.ParseUserID(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	return &Principal{UserID: userID, Scopes: strings.Fields(claims.Scope)}, nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//AuthorizationRequest is an OAuth 2.0 authorization code request with PKCE, see RFC 6749 section 4.1.1 and RFC 7636 section 4.3.
+type AuthorizationRequest struct {
+	//RedirectURI is redirect_uri parameter of the request, it may be empty if client has only one registered redirect URI.
+	RedirectURI         string
+	Scopes              []string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	//Nonce is an OpenID Connect nonce parameter, it is put into ID token as is.
+	Nonce string
+}
+
+//AuthorizationClient returns client of authorization request and redirect URI the response must be sent to.
+//Errors of this method must be reported to the user agent directly instead of redirecting it, see RFC 6749 section 4.1.2.1.
+func (s *AuthService) AuthorizationClient(ctx context.Context, clientID, redirectURI string) (*entity.Client, string, error) {
+	if clientID == "" {
+		return nil, "", &entity.ArgumentError{Message: "Client id is empty"}
+	}
+	client, err := s.clients.Get(ctx, clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if redirectURI == "" {
+		//Redirect URI may only be omitted if there is no choice, see RFC 6749 section 3.1.2.3.
+		if len(client.RedirectURIs) != 1 {
+			return nil, "", &entity.ArgumentError{Message: "Redirect URI is required"}
+		}
+		return client, client.RedirectURIs[0], nil
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, "", &entity.ArgumentError{Message: "Redirect URI is not registered for the client"}
+	}
+	return client, redirectURI, nil
+}
+
+//Authorize authenticates the user and issues authorization code for the client.
+//Code is only returned to the caller, hash of it is stored for AuthorizationCodeGrant.
+//Request is checked before the user is authenticated, so invalid requests are reported without asking the user to sign in.
+func (s *AuthService) Authorize(ctx context.Context, creds Credentials, client *entity.Client, req AuthorizationRequest) (string, error) {
+	if err := s.checkAuthorization(client, req); err != nil {
+		return "", err
+	}
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return "", err
+	}
+	return s.issueAuthorizationCode(ctx, principal.UserID, client, req)
+}
+
+//AuthorizeWithPassword checks password of the user who signed in at the login form of authorization endpoint
+//and issues authorization code for the client as Authorize does.
+func (s *AuthService) AuthorizeWithPassword(ctx context.Context, userID, password string, client *entity.Client, req AuthorizationRequest) (string, error) {
+	if err := s.checkAuthorization(client, req); err != nil {
+		return "", err
+	}
+	id, err := entity.ParseUserID(userID)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	if err := s.passwords.VerifyPassword(ctx, id, password); err != nil {
+		return "", err
+	}
+	return s.issueAuthorizationCode(ctx, id, client, req)
+}
+
+//checkAuthorization checks that the client may request authorization code with the scopes and PKCE code challenge of the request.
+func (s *AuthService) checkAuthorization(client *entity.Client, req AuthorizationRequest) error {
+	if !client.AllowsGrant(entity.GrantAuthorizationCode) {
+		return entity.ErrForbidden
+	}
+	if err := entity.ValidateCodeChallenge(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+		return err
+	}
+	if !client.AllowsScopes(req.Scopes) {
+		return entity.ErrInvalidScope
+	}
+	if containsScope(req.Scopes, entity.ScopeOpenID) && s.idTokenKey == nil {
+		return fmt.Errorf("%w: ID tokens are not issued", entity.ErrInvalidScope)
+	}
+	return nil
+}
+
+//issueAuthorizationCode issues authorization code of checked request for the authenticated user.
+func (s *AuthService) issueAuthorizationCode(ctx context.Context, userID entity.UserID, client *entity.Client, req AuthorizationRequest) (string, error) {
+	code, err := entity.GenerateAuthorizationCode()
+	if err != nil {
+		return "", err
+	}
+	err = s.codes.Insert(ctx, &entity.AuthorizationCode{
+		Hash:          entity.HashAuthorizationCode(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		Scopes:        req.Scopes,
+		Nonce:         req.Nonce,
+		AuthTime:      s.now(),
+		ExpiresAt:     s.now().Add(entity.AuthorizationCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+//AuthorizationCodeGrant redeems authorization code and issues a new pair of tokens as OAuth 2.0 authorization_code grant does.
+//Code can only be redeemed once by the client it was issued to, with the same redirect URI and matching PKCE code verifier.
+func (s *AuthService) AuthorizationCodeGrant(ctx context.Context, client *entity.Client, code, redirectURI, codeVerifier string) (*TokenPair, error) {
+	if !client.AllowsGrant(entity.GrantAuthorizationCode) {
+		return nil, entity.ErrForbidden
+	}
+	if code == "" {
+		return nil, &entity.ArgumentError{Message: "Authorization code is empty"}
+	}
+	if codeVerifier == "" {
+		return nil, &entity.ArgumentError{Message: "Code verifier is required"}
+	}
+
+	authCode, err := s.codes.Take(ctx, entity.HashAuthorizationCode(code))
+	if err != nil {
+		return nil, err
+	}
+	//Expired codes may outlive their lifetime until mongoDB TTL monitor removes them.
+	if !authCode.ExpiresAt.After(s.now()) {
+		return nil, fmt.Errorf("%w: code is expired", entity.ErrCodeNotFound)
+	}
+	if authCode.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: code was not issued to the client", entity.ErrInvalidGrant)
+	}
+	if authCode.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("%w: redirect URI does not match", entity.ErrInvalidGrant)
+	}
+	if err := authCode.VerifyCodeVerifier(codeVerifier); err != nil {
+		return nil, err
+	}
+	allowed, roles, err := s.allowedScopes(ctx, authCode.UserID, client)
+	if err != nil {
+		return nil, err
+	}
+	openID := containsScope(authCode.Scopes, entity.ScopeOpenID)
+	if openID {
+		allowed = append(allowed, entity.ScopeOpenID)
+	}
+	params := client.TokenParams(authCode.UserID, s.now())
+	params.Scopes = grantScopes(authCode.Scopes, allowed)
+	params.Roles = roles
+	if openID {
+		params.IDToken = &entity.IDTokenParams{
+			Issuer:   s.issuer,
+			Nonce:    authCode.Nonce,
+			AuthTime: authCode.AuthTime,
+			Key:      s.idTokenKey,
+		}
+	}
+	return s.issue(ctx, authCode.UserID, params)
+}
+
+//containsScope reports whether scope is in scopes.
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
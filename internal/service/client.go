@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//tokenEndpointPath is a path of OAuth 2.0 token endpoint relative to issuer.
+const tokenEndpointPath = "/oauth/token"
+
+//ClientCredentials are credentials presented by OAuth 2.0 client at the token endpoint.
+type ClientCredentials struct {
+	//Method is one of entity.ClientAuth* methods credentials were presented with.
+	Method       string
+	ClientID     string
+	ClientSecret string
+	//Assertion is a private_key_jwt client assertion.
+	Assertion string
+}
+
+//AuthenticateClient verifies client credentials and returns the client.
+//Clients registered with client_secret_basic or client_secret_post may use either of them.
+//It returns entity.ErrUnauthenticated if client is not registered or credentials are not valid.
+func (s *AuthService) AuthenticateClient(ctx context.Context, creds ClientCredentials) (*entity.Client, error) {
+	clientID := creds.ClientID
+	if clientID == "" && creds.Method == entity.ClientAuthPrivateKeyJWT {
+		issuer, err := entity.ClientAssertionIssuer(creds.Assertion)
+		if err != nil {
+			return nil, err
+		}
+		clientID = issuer
+	}
+	if clientID == "" {
+		return nil, entity.ErrUnauthenticated
+	}
+
+	client, err := s.clients.Get(ctx, clientID)
+	if errors.Is(err, entity.ErrClientNotFound) {
+		return nil, fmt.Errorf("%w: %s", entity.ErrUnauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch creds.Method {
+	case entity.ClientAuthSecretBasic, entity.ClientAuthSecretPost:
+		if client.AuthMethod != entity.ClientAuthSecretBasic && client.AuthMethod != entity.ClientAuthSecretPost {
+			return nil, fmt.Errorf("%w: client must authenticate with %s", entity.ErrUnauthenticated, client.AuthMethod)
+		}
+		err = s.hasher.Do(ctx, func() error {
+			return client.CompareSecret(creds.ClientSecret)
+		})
+	case entity.ClientAuthPrivateKeyJWT:
+		if client.AuthMethod != entity.ClientAuthPrivateKeyJWT {
+			return nil, fmt.Errorf("%w: client must authenticate with %s", entity.ErrUnauthenticated, client.AuthMethod)
+		}
+		issuer := strings.TrimSuffix(s.issuer, "/")
+		err = client.VerifyAssertion(creds.Assertion, s.now(), issuer, issuer+tokenEndpointPath)
+	case entity.ClientAuthNone:
+		if client.IsConfidential() {
+			return nil, fmt.Errorf("%w: client must authenticate with %s", entity.ErrUnauthenticated, client.AuthMethod)
+		}
+	default:
+		return nil, entity.ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+//RegisterClient registers a new OAuth 2.0 client described by metadata and returns it together with generated secret.
+//Secret is only returned once and is empty for clients that do not authenticate with a secret.
+//Caller must have admin scope.
+func (s *AuthService) RegisterClient(ctx context.Context, creds Credentials, metadata entity.Client) (*entity.Client, string, error) {
+	if _, err := s.authorizeAdmin(ctx, creds); err != nil {
+		return nil, "", err
+	}
+	client := metadata
+	if client.AuthMethod == "" {
+		//See RFC 7591 section 2.
+		client.AuthMethod = entity.ClientAuthSecretBasic
+	}
+	if err := client.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if client.AuthMethod == entity.ClientAuthSecretBasic || client.AuthMethod == entity.ClientAuthSecretPost {
+		var err error
+		secret, err = entity.GenerateClientSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash, err = s.hasher.Hash(ctx, secret)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if client.AuthMethod != entity.ClientAuthPrivateKeyJWT {
+		client.PublicKey = ""
+	}
+	client.ID = s.newID()
+	client.CreatedAt = s.now().Unix()
+	if err := s.clients.Create(ctx, &client); err != nil {
+		return nil, "", err
+	}
+	return &client, secret, nil
+}
+
+//RevokeClient deletes all refresh tokens issued to the client.
+//Caller must have admin scope.
+func (s *AuthService) RevokeClient(ctx context.Context, creds Credentials, clientID string) error {
+	if clientID == "" {
+		return &entity.ArgumentError{Message: "Client id is empty"}
+	}
+	principal, err := s.authorizeAdmin(ctx, creds)
+	if err != nil {
+		return err
+	}
+	if _, err := s.clients.Get(ctx, clientID); err != nil {
+		return err
+	}
+	event := s.auditEvent(ctx, entity.AuditRevoke, principal.UserID)
+	event.ClientID = clientID
+	return s.recordFailure(ctx, event, s.repo.DeleteClientRefreshTokens(ctx, clientID, event))
+}
+
+//authorizeAdmin authenticates the caller and checks it has admin scope.
+func (s *AuthService) authorizeAdmin(ctx context.Context, creds Credentials) (*Principal, error) {
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.HasScope(ScopeAdmin) {
+		return nil, entity.ErrForbidden
+	}
+	return principal, nil
+}
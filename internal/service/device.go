@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//DeviceAuthorization is a response to OAuth 2.0 device authorization request, see RFC 8628 section 3.2.
+type DeviceAuthorization struct {
+	DeviceCode string
+	//UserCode is a short code the user enters on another device to approve the request.
+	UserCode string
+	//ExpiresIn is a lifetime of the codes in seconds.
+	ExpiresIn int64
+	//Interval is a minimal polling interval in seconds.
+	Interval int64
+}
+
+//DeviceRequest is a pending device authorization request shown to the user before approval.
+type DeviceRequest struct {
+	ClientID  string
+	Scopes    []string
+	ExpiresAt int64
+}
+
+//AuthorizeDevice starts device authorization of the client.
+//Codes are only returned to the caller, hashes of them are stored for VerifyDevice and DeviceCodeGrant.
+func (s *AuthService) AuthorizeDevice(ctx context.Context, client *entity.Client, scopes []string) (*DeviceAuthorization, error) {
+	if !client.AllowsGrant(entity.GrantDeviceCode) {
+		return nil, entity.ErrForbidden
+	}
+	if !client.AllowsScopes(scopes) {
+		return nil, entity.ErrInvalidScope
+	}
+	if containsScope(scopes, entity.ScopeOpenID) {
+		return nil, fmt.Errorf("%w: ID tokens are not issued to devices", entity.ErrInvalidScope)
+	}
+
+	deviceCode, err := entity.GenerateDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := entity.GenerateUserCode()
+	if err != nil {
+		return nil, err
+	}
+	err = s.devices.Insert(ctx, &entity.DeviceCode{
+		Hash:         entity.HashDeviceCode(deviceCode),
+		UserCodeHash: entity.HashUserCode(userCode),
+		ClientID:     client.ID,
+		Scopes:       scopes,
+		Status:       entity.DeviceCodePending,
+		Interval:     entity.DevicePollInterval,
+		ExpiresAt:    s.now().Add(entity.DeviceCodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceAuthorization{
+		DeviceCode: deviceCode,
+		UserCode:   userCode,
+		ExpiresIn:  int64(entity.DeviceCodeTTL.Seconds()),
+		Interval:   entity.DevicePollInterval,
+	}, nil
+}
+
+//DeviceRequest authenticates the user and returns device authorization request with given user code.
+func (s *AuthService) DeviceRequest(ctx context.Context, creds Credentials, userCode string) (*DeviceRequest, error) {
+	if _, err := s.authenticator.Authenticate(ctx, creds); err != nil {
+		return nil, err
+	}
+	code, err := s.pendingDeviceCode(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceRequest{
+		ClientID:  code.ClientID,
+		Scopes:    code.Scopes,
+		ExpiresAt: code.ExpiresAt.Unix(),
+	}, nil
+}
+
+//VerifyDevice authenticates the user and records whether the user approved device authorization request with given user code.
+func (s *AuthService) VerifyDevice(ctx context.Context, creds Credentials, userCode string, approved bool) error {
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return err
+	}
+	if _, err := s.pendingDeviceCode(ctx, userCode); err != nil {
+		return err
+	}
+	return s.devices.Decide(ctx, entity.HashUserCode(userCode), principal.UserID, approved)
+}
+
+//pendingDeviceCode returns device code with given user code if the user has not decided on it yet.
+func (s *AuthService) pendingDeviceCode(ctx context.Context, userCode string) (*entity.DeviceCode, error) {
+	if userCode == "" {
+		return nil, &entity.ArgumentError{Message: "User code is empty"}
+	}
+	code, err := s.devices.GetByUserCode(ctx, entity.HashUserCode(userCode))
+	if err != nil {
+		return nil, err
+	}
+	//Expired codes may outlive their lifetime until mongoDB TTL monitor removes them.
+	if !code.ExpiresAt.After(s.now()) {
+		return nil, entity.ErrCodeExpired
+	}
+	if code.Status != entity.DeviceCodePending {
+		return nil, entity.ErrCodeUsed
+	}
+	return code, nil
+}
+
+//DeviceCodeGrant polls device authorization request and issues a new pair of tokens once the user approved it,
+//as OAuth 2.0 device_code grant does. Until then it returns entity.ErrAuthorizationPending,
+//entity.ErrSlowDown if the client polls too frequently and entity.ErrAccessDenied if the user denied the request.
+func (s *AuthService) DeviceCodeGrant(ctx context.Context, client *entity.Client, deviceCode string) (*TokenPair, error) {
+	if !client.AllowsGrant(entity.GrantDeviceCode) {
+		return nil, entity.ErrForbidden
+	}
+	if deviceCode == "" {
+		return nil, &entity.ArgumentError{Message: "Device code is empty"}
+	}
+
+	hash := entity.HashDeviceCode(deviceCode)
+	now := s.now()
+	code, err := s.devices.Poll(ctx, hash, now)
+	if err != nil {
+		return nil, err
+	}
+	if code.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: code was not issued to the client", entity.ErrInvalidGrant)
+	}
+	if !code.ExpiresAt.After(now) {
+		return nil, entity.ErrCodeExpired
+	}
+	if code.PolledTooSoon(now) {
+		if err := s.devices.SlowDown(ctx, hash); err != nil {
+			return nil, err
+		}
+		return nil, entity.ErrSlowDown
+	}
+	if code.Status == entity.DeviceCodePending {
+		return nil, entity.ErrAuthorizationPending
+	}
+
+	//Decided code is taken so only one of concurrent polls gets the result.
+	code, err = s.devices.Take(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if code.Status == entity.DeviceCodeDenied {
+		return nil, entity.ErrAccessDenied
+	}
+	allowed, roles, err := s.allowedScopes(ctx, code.UserID, client)
+	if err != nil {
+		return nil, err
+	}
+	params := client.TokenParams(code.UserID, now)
+	params.Scopes = grantScopes(code.Scopes, allowed)
+	params.Roles = roles
+	return s.issue(ctx, code.UserID, params)
+}
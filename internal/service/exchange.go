@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//TokenExchangeRequest is an OAuth 2.0 token exchange request, see RFC 8693 section 2.1.
+type TokenExchangeRequest struct {
+	SubjectToken     string
+	SubjectTokenType string
+	//ActorToken is an optional access token of the party acting on behalf of the subject.
+	//Client performing the exchange is the actor if it is empty.
+	ActorToken     string
+	ActorTokenType string
+	//Audience is a logical name of the service exchanged token is intended for.
+	Audience string
+	//Scopes may narrow scopes of subject token, nil keeps them.
+	Scopes             []string
+	RequestedTokenType string
+}
+
+//TokenExchangeGrant exchanges access token of the user for a new access token for the target audience
+//as OAuth 2.0 token exchange grant does. New token never outlives subject token,
+//it`s scopes are narrowed to the ones of the client and act claim records the actor together with it`s own chain
+//on top of the chain of subject token.
+//Requesting scope subject token was not granted is reported as entity.ErrInvalidScope.
+func (s *AuthService) TokenExchangeGrant(ctx context.Context, client *entity.Client, req TokenExchangeRequest) (*TokenPair, error) {
+	event := s.auditEvent(ctx, entity.AuditIssue, "")
+	event.ClientID = client.ID
+	if !client.AllowsGrant(entity.GrantTokenExchange) {
+		return nil, s.recordFailure(ctx, event, entity.ErrForbidden)
+	}
+	if req.SubjectToken == "" {
+		return nil, &entity.ArgumentError{Message: "Subject token is empty"}
+	}
+	if req.SubjectTokenType != entity.TokenTypeAccessToken {
+		return nil, &entity.ArgumentError{Message: "Subject token type is not supported"}
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != entity.TokenTypeAccessToken {
+		return nil, &entity.ArgumentError{Message: "Requested token type is not supported"}
+	}
+	if req.Audience == "" {
+		return nil, &entity.ArgumentError{Message: "Audience is required"}
+	}
+
+	subject, err := entity.ParseAccessToken(ctx, req.SubjectToken)
+	if err != nil {
+		return nil, s.recordFailure(ctx, event, err)
+	}
+	userID, err := claimsUserID(subject.User_id)
+	if err != nil {
+		return nil, s.recordFailure(ctx, event, fmt.Errorf("%w: subject token is not issued to a user", entity.ErrInvalidGrant))
+	}
+	event.UserID = userID
+	actor := &entity.Actor{Sub: client.ID}
+	if req.ActorToken != "" {
+		if req.ActorTokenType != entity.TokenTypeAccessToken {
+			return nil, &entity.ArgumentError{Message: "Actor token type is not supported"}
+		}
+		actorClaims, err := entity.ParseAccessToken(ctx, req.ActorToken)
+		if err != nil {
+			return nil, err
+		}
+		//Actor token may itself be exchanged, then it`s act claim is the chain the actor acts through.
+		actor = &entity.Actor{Sub: actorClaims.Subject(), Act: actorClaims.Act}
+	}
+	//Prior actors of subject token follow the new ones, so the whole chain of delegation is kept.
+	actor = actor.Chain(subject.Act)
+
+	granted := entity.ParseScope(subject.Scope)
+	if req.Scopes != nil && !entity.IsScopeSubset(req.Scopes, granted) {
+		return nil, entity.ErrInvalidScope
+	}
+	now, err := s.issueTime(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	params := client.TokenParams(userID, now)
+	params.Scopes = grantScopes(req.Scopes, entity.IntersectScopes(granted, client.Scopes))
+	params.Roles = subject.Roles
+	params.Audience = req.Audience
+	params.Actor = actor
+	params.AccessTokenTTL = exchangedTokenTTL(params.AccessTokenTTL, subject, now)
+	accessToken, err := entity.NewAccessToken(params)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, event)
+	return &TokenPair{
+		AccessToken:          accessToken.Token,
+		AccessTokenExpiresAt: accessToken.ExpiresAt,
+		Scopes:               params.Scopes,
+	}, nil
+}
+
+//exchangedTokenTTL returns lifetime of exchanged token, it is cut to the remaining lifetime of subject token.
+func exchangedTokenTTL(ttl time.Duration, subject *entity.CustomClaimsAcessToken, now time.Time) time.Duration {
+	if ttl == 0 {
+		ttl = entity.AccessTokenTTL
+	}
+	if subject.ExpiresAt == 0 {
+		return ttl
+	}
+	if remaining := time.Unix(subject.ExpiresAt, 0).Sub(now); remaining < ttl {
+		return remaining
+	}
+	return ttl
+}
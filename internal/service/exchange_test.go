@@ -0,0 +1,83 @@
+package service_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+)
+
+func TestTokenExchangeActor(t *testing.T) {
+	st := newServiceTest(t)
+	ctx := context.Background()
+	client := &entity.Client{ID: "client", GrantTypes: []string{entity.GrantTokenExchange}, Scopes: []string{"read"}}
+	//token returns access token of the subject issued at the fixed time with given act claim.
+	token := func(userID entity.UserID, clientID string, act *entity.Actor) string {
+		accessToken, err := entity.NewAccessToken(entity.TokenParams{
+			UserID:   userID,
+			ClientID: clientID,
+			IssuedAt: st.now,
+			Scopes:   []string{"read"},
+			Actor:    act,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return accessToken.Token
+	}
+	prior := &entity.Actor{Sub: "gateway", Act: &entity.Actor{Sub: "frontend"}}
+
+	tests := []struct {
+		name    string
+		subject string
+		actor   string
+		act     *entity.Actor
+	}{
+		{"client acts", token(userID, "", nil), "", &entity.Actor{Sub: "client"}},
+		{"client acts after prior actors", token(userID, "", prior), "", &entity.Actor{Sub: "client", Act: prior}},
+		{"user of actor token acts", token(userID, "", nil), token(adminID, "", nil), &entity.Actor{Sub: string(adminID)}},
+		{"client of actor token acts", token(userID, "", nil), token("", "service", nil), &entity.Actor{Sub: "service"}},
+		{"actor acts through own chain", token(userID, "", nil), token(adminID, "", &entity.Actor{Sub: "console"}),
+			&entity.Actor{Sub: string(adminID), Act: &entity.Actor{Sub: "console"}}},
+		{"both chains are kept", token(userID, "", prior), token(adminID, "", &entity.Actor{Sub: "console"}),
+			&entity.Actor{Sub: string(adminID), Act: &entity.Actor{Sub: "console", Act: prior}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := service.TokenExchangeRequest{
+				SubjectToken:     tt.subject,
+				SubjectTokenType: entity.TokenTypeAccessToken,
+				Audience:         "https://api.example.com",
+			}
+			if tt.actor != "" {
+				req.ActorToken = tt.actor
+				req.ActorTokenType = entity.TokenTypeAccessToken
+			}
+			exchanged, err := st.auth.TokenExchangeGrant(ctx, client, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := entity.ParseAccessToken(ctx, exchanged.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(claims.Act, tt.act) {
+				t.Errorf("got act %s, want %s", chain(claims.Act), chain(tt.act))
+			}
+			if claims.User_id != string(userID) || claims.Audience != req.Audience {
+				t.Errorf("got token of %s for %s, want token of %s for %s", claims.User_id, claims.Audience, userID, req.Audience)
+			}
+		})
+	}
+}
+
+//chain formats the chain of actors from the current one to the first one.
+func chain(act *entity.Actor) string {
+	s := ""
+	for ; act != nil; act = act.Act {
+		s += act.Sub + " <- "
+	}
+	return s + "subject"
+}
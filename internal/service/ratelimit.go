@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/repository"
+)
+
+//RateLimiter throttles requests to routes with token buckets of every key of the caller, e.g. IP address and user id.
+type RateLimiter struct {
+	buckets repository.RateLimit
+	limits  entity.RateLimits
+	now     Clock
+}
+
+//NewRateLimiter returns a new RateLimiter.
+func NewRateLimiter(buckets repository.RateLimit, limits entity.RateLimits) *RateLimiter {
+	return &RateLimiter{
+		buckets: buckets,
+		limits:  limits,
+		now:     time.Now,
+	}
+}
+
+//Allow takes a token from buckets of the route for every key, but only if all of them have one, so a request
+//rejected by one key does not use up the others. It returns zero if the request is allowed
+//and time to wait before retrying otherwise. Requests are allowed if buckets storage is unavailable,
+//so outage of shared storage does not lock everybody out.
+func (l *RateLimiter) Allow(ctx context.Context, route string, keys ...string) time.Duration {
+	limit, ok := l.limits.For(route)
+	if !ok || len(keys) == 0 {
+		return 0
+	}
+	bucketKeys := make([]string, len(keys))
+	for i, key := range keys {
+		bucketKeys[i] = route + " " + key
+	}
+	wait, err := l.buckets.Take(ctx, bucketKeys, limit, l.now())
+	if err != nil {
+		log.Printf("Rate limit of %s is not checked: %s", route, err.Error())
+		return 0
+	}
+	return wait
+}
@@ -0,0 +1,41 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/repository/ratelimit/memory"
+	"example.com/auth-service-go/internal/service"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limits, err := entity.ParseRateLimits("POST /auth/login=2/1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := service.NewRateLimiter(memory.NewRateLimitRepository(), limits)
+	ctx := context.Background()
+	const route = "POST /auth/login"
+
+	//Requests are done in order, the ip is shared by two users.
+	tests := []struct {
+		name    string
+		route   string
+		keys    []string
+		allowed bool
+	}{
+		{"first login", route, []string{"ip:1", "user:a"}, true},
+		{"second login", route, []string{"ip:2", "user:a"}, true},
+		{"user is limited", route, []string{"ip:1", "user:a"}, false},
+		{"rejected request left ip bucket", route, []string{"ip:1", "user:b"}, true},
+		{"ip is limited", route, []string{"ip:1", "user:c"}, false},
+		{"route without limit", "GET /users/{userID}", []string{"ip:1", "user:a"}, true},
+		{"no keys", route, nil, true},
+	}
+	for _, tt := range tests {
+		if wait := l.Allow(ctx, tt.route, tt.keys...); (wait == 0) != tt.allowed {
+			t.Fatalf("%s: got wait %s, want allowed %v", tt.name, wait, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//ScopePolicy maps roles of users onto scopes that may be granted to their tokens.
+type ScopePolicy struct {
+	roles map[string][]string
+}
+
+//NewScopePolicy returns ScopePolicy from roles specification.
+//Specification is a semicolon separated list of role:scope,scope entries.
+func NewScopePolicy(spec string) (*ScopePolicy, error) {
+	p := &ScopePolicy{roles: map[string][]string{}}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Role entry %q is not valid", entry)
+		}
+		p.roles[parts[0]] = append(p.roles[parts[0]], entity.ParseScope(strings.Replace(parts[1], ",", " ", -1))...)
+	}
+	return p, nil
+}
+
+//UserScopes returns scopes which may be granted to tokens of user with given roles.
+func (p *ScopePolicy) UserScopes(roles []string) []string {
+	var scopes []string
+	for _, role := range roles {
+		for _, scope := range p.roles[role] {
+			if !containsScope(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+//grantScopes returns requested scopes which are allowed. All allowed scopes are granted if no scopes were requested.
+func grantScopes(requested, allowed []string) []string {
+	if requested == nil {
+		return allowed
+	}
+	return entity.IntersectScopes(requested, allowed)
+}
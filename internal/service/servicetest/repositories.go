@@ -0,0 +1,496 @@
+//Package servicetest provides in-memory repositories for tests of the transports built on top of service.AuthService.
+package servicetest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+)
+
+//Repositories are in-memory implementations of all repositories used by service.AuthService.
+//Refresh tokens are stored as keyed digests whatever their format and session limits are not enforced.
+type Repositories struct {
+	Tokens     *Tokens
+	Users      *Users
+	Clients    *Clients
+	Codes      *AuthorizationCodes
+	Devices    *DeviceCodes
+	Watermarks *Watermarks
+	Audit      *Audit
+}
+
+//New returns empty repositories. Token repository records it`s events in Audit.
+func New() *Repositories {
+	audit := &Audit{}
+	return &Repositories{
+		Tokens:     &Tokens{tokens: map[string]entity.RefreshToken{}, audit: audit},
+		Users:      &Users{users: map[entity.UserID]entity.User{}},
+		Clients:    &Clients{clients: map[string]entity.Client{}},
+		Codes:      &AuthorizationCodes{codes: map[string]entity.AuthorizationCode{}},
+		Devices:    &DeviceCodes{codes: map[string]entity.DeviceCode{}},
+		Watermarks: &Watermarks{watermarks: map[string]int64{}},
+		Audit:      audit,
+	}
+}
+
+//AuthService returns service.AuthService backed by the repositories.
+func (r *Repositories) AuthService(authenticator service.Authenticator, opts ...service.Option) *service.AuthService {
+	return service.NewAuthService(r.Tokens, r.Users, r.Clients, r.Codes, r.Devices, r.Watermarks, r.Audit, authenticator, opts...)
+}
+
+//Tokens is an in-memory repository.Token.
+type Tokens struct {
+	mu     sync.Mutex
+	tokens map[string]entity.RefreshToken
+	audit  *Audit
+}
+
+//Insert implements repository.Token.
+func (t *Tokens) Insert(ctx context.Context, tokenPair *entity.TokenPair, event entity.AuditEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	refreshToken := tokenPair.RefreshToken
+	refreshToken.Token = entity.RefreshTokenDigest(refreshToken.Token)
+	t.tokens[refreshToken.UUID] = refreshToken
+	event.UserID = refreshToken.UserID
+	event.ClientID = refreshToken.ClientID
+	event.SessionID = refreshToken.SessionID
+	event.TokenIDs = []string{refreshToken.UUID}
+	t.audit.append(event)
+	return nil
+}
+
+//DeleteUserRefreshTokens implements repository.Token.
+func (t *Tokens) DeleteUserRefreshTokens(ctx context.Context, userID entity.UserID, event entity.AuditEvent) error {
+	event.UserID = userID
+	t.deleteRecorded(func(token entity.RefreshToken) bool { return token.UserID == userID }, event)
+	return nil
+}
+
+//DeleteClientRefreshTokens implements repository.Token.
+func (t *Tokens) DeleteClientRefreshTokens(ctx context.Context, clientID string, event entity.AuditEvent) error {
+	event.ClientID = clientID
+	t.deleteRecorded(func(token entity.RefreshToken) bool { return token.ClientID == clientID }, event)
+	return nil
+}
+
+//DeleteRefreshToken implements repository.Token.
+func (t *Tokens) DeleteRefreshToken(ctx context.Context, userID entity.UserID, refreshTokenUUID string, event entity.AuditEvent) error {
+	event.UserID = userID
+	event.TokenIDs = []string{refreshTokenUUID}
+	deleted := t.deleteRecorded(func(token entity.RefreshToken) bool {
+		return token.UUID == refreshTokenUUID && token.UserID == userID
+	}, event)
+	if deleted == 0 {
+		return entity.ErrTokenNotFound
+	}
+	return nil
+}
+
+//CheckRefreshToken implements repository.Token.
+func (t *Tokens) CheckRefreshToken(ctx context.Context, refreshTokenUUID string) error {
+	_, err := t.GetRefreshToken(ctx, refreshTokenUUID)
+	return err
+}
+
+//GetRefreshToken implements repository.Token.
+func (t *Tokens) GetRefreshToken(ctx context.Context, refreshTokenUUID string) (*entity.RefreshToken, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	refreshToken, ok := t.tokens[refreshTokenUUID]
+	if !ok {
+		return nil, entity.ErrTokenNotFound
+	}
+	if refreshToken.Used {
+		return nil, entity.ErrTokenUsed
+	}
+	return &refreshToken, nil
+}
+
+//RefreshTokenSetIsUsed implements repository.Token.
+func (t *Tokens) RefreshTokenSetIsUsed(ctx context.Context, refreshTokenUUID string, event entity.AuditEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	refreshToken, ok := t.tokens[refreshTokenUUID]
+	if !ok {
+		return entity.ErrTokenNotFound
+	}
+	if refreshToken.Used {
+		return entity.ErrTokenUsed
+	}
+	refreshToken.Used = true
+	t.tokens[refreshTokenUUID] = refreshToken
+	event.UserID = refreshToken.UserID
+	event.ClientID = refreshToken.ClientID
+	event.SessionID = sessionID(refreshToken)
+	event.TokenIDs = []string{refreshToken.UUID}
+	t.audit.append(event)
+	return nil
+}
+
+//UserRefreshTokens implements repository.Token.
+func (t *Tokens) UserRefreshTokens(ctx context.Context, userID entity.UserID) ([]entity.RefreshToken, error) {
+	refreshTokens := t.find(func(token entity.RefreshToken) bool { return token.UserID == userID && !token.Used })
+	sort.Slice(refreshTokens, func(i, j int) bool { return refreshTokens[i].CreatedAt > refreshTokens[j].CreatedAt })
+	return refreshTokens, nil
+}
+
+//DeleteSession implements repository.Token.
+func (t *Tokens) DeleteSession(ctx context.Context, userID entity.UserID, sessionID string, event entity.AuditEvent) error {
+	event.UserID = userID
+	event.SessionID = sessionID
+	deleted := t.deleteRecorded(func(token entity.RefreshToken) bool {
+		return token.UserID == userID && inSession(token, sessionID)
+	}, event)
+	if deleted == 0 {
+		return entity.ErrSessionNotFound
+	}
+	return nil
+}
+
+//FindRefreshTokens implements repository.Token.
+func (t *Tokens) FindRefreshTokens(ctx context.Context, filter entity.TokenFilter, offset, limit int) ([]entity.RefreshToken, error) {
+	refreshTokens := t.find(func(token entity.RefreshToken) bool { return matches(token, filter) })
+	sort.Slice(refreshTokens, func(i, j int) bool {
+		if refreshTokens[i].RotatedAt != refreshTokens[j].RotatedAt {
+			return refreshTokens[i].RotatedAt > refreshTokens[j].RotatedAt
+		}
+		return refreshTokens[i].UUID < refreshTokens[j].UUID
+	})
+	low, high := bounds(len(refreshTokens), offset, limit)
+	return refreshTokens[low:high], nil
+}
+
+//DeleteRefreshTokens implements repository.Token.
+func (t *Tokens) DeleteRefreshTokens(ctx context.Context, filter entity.TokenFilter, event entity.AuditEvent) (int64, error) {
+	return t.deleteRecorded(func(token entity.RefreshToken) bool { return matches(token, filter) }, event), nil
+}
+
+//find returns copies of the tokens matching the predicate.
+func (t *Tokens) find(match func(entity.RefreshToken) bool) []entity.RefreshToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	refreshTokens := []entity.RefreshToken{}
+	for _, refreshToken := range t.tokens {
+		if match(refreshToken) {
+			refreshTokens = append(refreshTokens, refreshToken)
+		}
+	}
+	return refreshTokens
+}
+
+//deleteRecorded deletes tokens matching the predicate and records the event with their number if there were any.
+func (t *Tokens) deleteRecorded(match func(entity.RefreshToken) bool, event entity.AuditEvent) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var deleted int64
+	for id, refreshToken := range t.tokens {
+		if match(refreshToken) {
+			delete(t.tokens, id)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		event.Count = deleted
+		t.audit.append(event)
+	}
+	return deleted
+}
+
+//sessionID returns session of the token, tokens issued before sessions were recorded are their own session.
+func sessionID(refreshToken entity.RefreshToken) string {
+	if refreshToken.SessionID == "" {
+		return refreshToken.UUID
+	}
+	return refreshToken.SessionID
+}
+
+func inSession(refreshToken entity.RefreshToken, id string) bool {
+	return sessionID(refreshToken) == id
+}
+
+//matches reports whether token matches the filter the way mongo token repository does.
+func matches(refreshToken entity.RefreshToken, filter entity.TokenFilter) bool {
+	switch {
+	case filter.UserID != "" && refreshToken.UserID != filter.UserID:
+		return false
+	case filter.ClientID != "" && refreshToken.ClientID != filter.ClientID:
+		return false
+	case filter.SessionID != "" && !inSession(refreshToken, filter.SessionID):
+		return false
+	case !filter.IssuedAfter.IsZero() && refreshToken.RotatedAt <= filter.IssuedAfter.Unix():
+		return false
+	case !filter.IssuedBefore.IsZero() && refreshToken.RotatedAt != 0 && refreshToken.RotatedAt >= filter.IssuedBefore.Unix():
+		return false
+	}
+	return filter.Status == "" || refreshToken.Status(filter.Now) == filter.Status
+}
+
+//Users is an in-memory repository.User.
+type Users struct {
+	mu    sync.Mutex
+	users map[entity.UserID]entity.User
+}
+
+//Create implements repository.User.
+func (u *Users) Create(ctx context.Context, user *entity.User) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.users[user.ID]; ok {
+		return entity.ErrUserExists
+	}
+	u.users[user.ID] = *user
+	return nil
+}
+
+//Get implements repository.User.
+func (u *Users) Get(ctx context.Context, userID entity.UserID) (*entity.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[userID]
+	if !ok {
+		return nil, entity.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+//UpdatePassword implements repository.User.
+func (u *Users) UpdatePassword(ctx context.Context, userID entity.UserID, passwordHash string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[userID]
+	if !ok {
+		return entity.ErrUserNotFound
+	}
+	user.PasswordHash = passwordHash
+	u.users[userID] = user
+	return nil
+}
+
+//Clients is an in-memory repository.Client.
+type Clients struct {
+	mu      sync.Mutex
+	clients map[string]entity.Client
+}
+
+//Create implements repository.Client.
+func (c *Clients) Create(ctx context.Context, client *entity.Client) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients[client.ID] = *client
+	return nil
+}
+
+//Get implements repository.Client.
+func (c *Clients) Get(ctx context.Context, clientID string) (*entity.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client, ok := c.clients[clientID]
+	if !ok {
+		return nil, entity.ErrClientNotFound
+	}
+	return &client, nil
+}
+
+//AuthorizationCodes is an in-memory repository.AuthorizationCode.
+type AuthorizationCodes struct {
+	mu    sync.Mutex
+	codes map[string]entity.AuthorizationCode
+}
+
+//Insert implements repository.AuthorizationCode.
+func (a *AuthorizationCodes) Insert(ctx context.Context, code *entity.AuthorizationCode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.codes[code.Hash] = *code
+	return nil
+}
+
+//Take implements repository.AuthorizationCode.
+func (a *AuthorizationCodes) Take(ctx context.Context, hash string) (*entity.AuthorizationCode, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	code, ok := a.codes[hash]
+	if !ok {
+		return nil, entity.ErrCodeNotFound
+	}
+	delete(a.codes, hash)
+	return &code, nil
+}
+
+//DeviceCodes is an in-memory repository.DeviceCode.
+type DeviceCodes struct {
+	mu    sync.Mutex
+	codes map[string]entity.DeviceCode
+}
+
+//Insert implements repository.DeviceCode.
+func (d *DeviceCodes) Insert(ctx context.Context, code *entity.DeviceCode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.codes[code.Hash] = *code
+	return nil
+}
+
+//GetByUserCode implements repository.DeviceCode.
+func (d *DeviceCodes) GetByUserCode(ctx context.Context, hash string) (*entity.DeviceCode, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, code := range d.codes {
+		if code.UserCodeHash == hash {
+			return &code, nil
+		}
+	}
+	return nil, entity.ErrCodeNotFound
+}
+
+//Decide implements repository.DeviceCode.
+func (d *DeviceCodes) Decide(ctx context.Context, hash string, userID entity.UserID, approved bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, code := range d.codes {
+		if code.UserCodeHash != hash {
+			continue
+		}
+		if code.Status != entity.DeviceCodePending {
+			return entity.ErrCodeUsed
+		}
+		code.Status = entity.DeviceCodeDenied
+		if approved {
+			code.Status = entity.DeviceCodeApproved
+		}
+		code.UserID = userID
+		d.codes[id] = code
+		return nil
+	}
+	return entity.ErrCodeNotFound
+}
+
+//Poll implements repository.DeviceCode.
+func (d *DeviceCodes) Poll(ctx context.Context, hash string, now time.Time) (*entity.DeviceCode, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	code, ok := d.codes[hash]
+	if !ok {
+		return nil, entity.ErrCodeNotFound
+	}
+	polled := code
+	polled.LastPolledAt = now
+	d.codes[hash] = polled
+	return &code, nil
+}
+
+//SlowDown implements repository.DeviceCode.
+func (d *DeviceCodes) SlowDown(ctx context.Context, hash string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if code, ok := d.codes[hash]; ok {
+		code.Interval += entity.DeviceSlowDown
+		d.codes[hash] = code
+	}
+	return nil
+}
+
+//Take implements repository.DeviceCode.
+func (d *DeviceCodes) Take(ctx context.Context, hash string) (*entity.DeviceCode, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	code, ok := d.codes[hash]
+	if !ok {
+		return nil, entity.ErrCodeNotFound
+	}
+	delete(d.codes, hash)
+	return &code, nil
+}
+
+//Watermarks is an in-memory repository.Watermark.
+type Watermarks struct {
+	mu         sync.Mutex
+	watermarks map[string]int64
+}
+
+//NotBefore implements repository.Watermark.
+func (w *Watermarks) NotBefore(ctx context.Context, userID entity.UserID) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	notBefore := w.watermarks[entity.GlobalWatermark]
+	if userID != "" && w.watermarks[string(userID)] > notBefore {
+		notBefore = w.watermarks[string(userID)]
+	}
+	return notBefore, nil
+}
+
+//SetNotBefore implements repository.Watermark.
+func (w *Watermarks) SetNotBefore(ctx context.Context, userID entity.UserID, notBefore int64) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := entity.GlobalWatermark
+	if userID != "" {
+		key = string(userID)
+	}
+	if notBefore > w.watermarks[key] {
+		w.watermarks[key] = notBefore
+	}
+	return w.watermarks[key], nil
+}
+
+//Audit is an in-memory repository.Audit.
+type Audit struct {
+	mu     sync.Mutex
+	events []entity.AuditEvent
+}
+
+//Record implements repository.Audit.
+func (a *Audit) Record(ctx context.Context, event entity.AuditEvent) error {
+	a.append(event)
+	return nil
+}
+
+//FindAuditEvents implements repository.Audit.
+func (a *Audit) FindAuditEvents(ctx context.Context, filter entity.AuditFilter, offset, limit int) ([]entity.AuditEvent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	events := []entity.AuditEvent{}
+	for _, event := range a.events {
+		switch {
+		case filter.Type != "" && event.Type != filter.Type:
+		case filter.Actor != "" && event.Actor != filter.Actor:
+		case filter.UserID != "" && event.UserID != filter.UserID:
+		case filter.ClientID != "" && event.ClientID != filter.ClientID:
+		case !filter.After.IsZero() && event.Time <= filter.After.Unix():
+		case !filter.Before.IsZero() && event.Time >= filter.Before.Unix():
+		default:
+			events = append(events, event)
+		}
+	}
+	low, high := bounds(len(events), offset, limit)
+	return events[low:high], nil
+}
+
+//Events returns all recorded events in order they were recorded.
+func (a *Audit) Events() []entity.AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]entity.AuditEvent(nil), a.events...)
+}
+
+func (a *Audit) append(event entity.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, event)
+}
+
+//bounds returns bounds of the page of at most limit of n elements after skipping offset of them.
+func bounds(n, offset, limit int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	if limit > n-offset {
+		limit = n - offset
+	}
+	return offset, offset + limit
+}
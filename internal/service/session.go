@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//Device describes the device tokens are issued to.
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+type deviceKey struct{}
+
+//ContextWithDevice returns a copy of ctx carrying the device of the request.
+//Sessions started or rotated with the returned context record the device.
+func ContextWithDevice(ctx context.Context, device Device) context.Context {
+	return context.WithValue(ctx, deviceKey{}, device)
+}
+
+//DeviceFromContext returns the device carried by ctx, it is zero if there is none.
+func DeviceFromContext(ctx context.Context) Device {
+	device, _ := ctx.Value(deviceKey{}).(Device)
+	return device
+}
+
+//Session is a family of refresh tokens obtained from each other by rotation.
+type Session struct {
+	ID       string
+	ClientID string
+	Device   Device
+	//CreatedAt, RotatedAt and ExpiresAt are unix times the session was started at,
+	//it`s refresh token was last rotated at and the refresh token expires at.
+	CreatedAt int64
+	RotatedAt int64
+	ExpiresAt int64
+	//Current reports whether the caller authenticated with access token of the session.
+	Current bool
+}
+
+//Sessions authenticates the caller and returns active sessions of the caller, the most recent first.
+func (s *AuthService) Sessions(ctx context.Context, creds Credentials) ([]Session, error) {
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	refreshTokens, err := s.repo.UserRefreshTokens(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var currentUUID string
+	if claims, err := entity.ParseAccessToken(ctx, creds.BearerToken); err == nil {
+		currentUUID = claims.Refresh_uuid
+	}
+	now := s.now().Unix()
+	sessions := []Session{}
+	for _, refreshToken := range refreshTokens {
+		if refreshToken.ExpiresAt <= now {
+			continue
+		}
+		session := Session{
+			ID:        refreshToken.SessionID,
+			ClientID:  refreshToken.ClientID,
+			Device:    Device{UserAgent: refreshToken.UserAgent, IP: refreshToken.IP},
+			CreatedAt: refreshToken.CreatedAt,
+			RotatedAt: refreshToken.RotatedAt,
+			ExpiresAt: refreshToken.ExpiresAt,
+			Current:   refreshToken.UUID == currentUUID,
+		}
+		//Tokens issued before sessions were recorded are sessions by themselves.
+		if session.ID == "" {
+			session.ID = refreshToken.UUID
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+//RevokeSession authenticates the caller and deletes refresh tokens of the caller`s session with given id.
+//Access tokens of the session stay valid until they expire.
+func (s *AuthService) RevokeSession(ctx context.Context, creds Credentials, sessionID string) error {
+	if sessionID == "" {
+		return &entity.ArgumentError{Message: "Session id is empty"}
+	}
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return err
+	}
+	event := s.auditEvent(ctx, entity.AuditRevoke, principal.UserID)
+	event.UserID = principal.UserID
+	event.SessionID = sessionID
+	return s.recordFailure(ctx, event, s.repo.DeleteSession(ctx, principal.UserID, sessionID, event))
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/repository"
+)
+
+//Register creates a new user account with given password and returns id of the user.
+func (s *AuthService) Register(ctx context.Context, password string) (entity.UserID, error) {
+	if err := entity.ValidatePassword(password); err != nil {
+		return "", err
+	}
+	hash, err := s.passwords.hash(ctx, password)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := entity.ParseUserID(s.newID())
+	if err != nil {
+		return "", err
+	}
+	now := s.now().Unix()
+	user := &entity.User{
+		ID:           userID,
+		PasswordHash: hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+//Login verifies password of the user and issues a new pair of tokens with requested scopes narrowed by the policy.
+func (s *AuthService) Login(ctx context.Context, userID entity.UserID, password string, scopes []string) (*TokenPair, error) {
+	if userID == "" {
+		return nil, &entity.ArgumentError{Message: "User id is empty"}
+	}
+	if err := s.passwords.VerifyPassword(ctx, userID, password); err != nil {
+		return nil, err
+	}
+	return s.issueForUser(ctx, userID, userID, scopes)
+}
+
+//ChangePassword changes password of the authenticated caller and revokes all it`s tokens.
+//Refresh tokens are deleted and access tokens are revoked by not before watermark of the user, as they can not be deleted.
+func (s *AuthService) ChangePassword(ctx context.Context, creds Credentials, oldPassword, newPassword string) error {
+	principal, err := s.authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		return err
+	}
+	if err := entity.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	if err := s.passwords.VerifyPassword(ctx, principal.UserID, oldPassword); err != nil {
+		return err
+	}
+	if err := s.passwords.SetPassword(ctx, principal.UserID, newPassword); err != nil {
+		return err
+	}
+	if _, err := s.watermarks.SetNotBefore(ctx, principal.UserID, s.now().Unix()); err != nil {
+		return err
+	}
+	event := s.auditEvent(ctx, entity.AuditRevoke, principal.UserID)
+	event.UserID = principal.UserID
+	return s.recordFailure(ctx, event, s.repo.DeleteUserRefreshTokens(ctx, principal.UserID, event))
+}
+
+//UserPasswords verifies and changes passwords of users stored in user repository.
+//Password hashes are generated and compared by hasher.
+type UserPasswords struct {
+	users  repository.User
+	hasher Hasher
+}
+
+//NewUserPasswords returns a new UserPasswords.
+func NewUserPasswords(users repository.User, hasher Hasher) *UserPasswords {
+	return &UserPasswords{
+		users:  users,
+		hasher: hasher,
+	}
+}
+
+//VerifyPassword implements PasswordVerifier.
+//Hashes generated with outdated parameters or bcrypt are upgraded on successful verification.
+func (p *UserPasswords) VerifyPassword(ctx context.Context, userID entity.UserID, password string) error {
+	if password == "" || len(password) > entity.MaxPasswordLength {
+		return entity.ErrUnauthenticated
+	}
+	user, err := p.users.Get(ctx, userID)
+	if errors.Is(err, entity.ErrUserNotFound) {
+		//Do not reveal whether user exists.
+		return entity.ErrUnauthenticated
+	}
+	if err != nil {
+		return err
+	}
+	err = p.hasher.Do(ctx, func() error {
+		return entity.ComparePasswordHash(user.PasswordHash, password)
+	})
+	if err != nil {
+		return err
+	}
+
+	if entity.NeedsRehash(user.PasswordHash) {
+		if err := p.SetPassword(ctx, userID, password); err != nil {
+			log.Printf("Error upgrading password hash of user with id=%v: %s", userID, err.Error())
+		}
+	}
+	return nil
+}
+
+//SetPassword stores a new password hash of the user.
+func (p *UserPasswords) SetPassword(ctx context.Context, userID entity.UserID, password string) error {
+	hash, err := p.hash(ctx, password)
+	if err != nil {
+		return err
+	}
+	return p.users.UpdatePassword(ctx, userID, hash)
+}
+
+//hash generates argon2id hash of password.
+func (p *UserPasswords) hash(ctx context.Context, password string) (string, error) {
+	var hash string
+	err := p.hasher.Do(ctx, func() error {
+		var err error
+		hash, err = entity.GeneratePasswordHash(password)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
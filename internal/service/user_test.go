@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/service"
+)
+
+func TestChangePasswordRevokesTokensOfTheSameSecond(t *testing.T) {
+	st := newServiceTest(t)
+	entity.UseWatermarks(st.repos.Watermarks)
+	defer entity.UseWatermarks(nil)
+	ctx := context.Background()
+	const password = "correct horse battery staple"
+
+	id, err := st.auth.Register(ctx, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Clock stands still, so the tokens are issued in the same second as the password is changed.
+	stolen, err := st.auth.Login(ctx, id, password, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caller := service.Credentials{BearerToken: stolen.AccessToken}
+	if err := st.auth.ChangePassword(ctx, caller, password, password+"!"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.auth.Validate(ctx, stolen.AccessToken)
+	wantErr(t, err, entity.ErrInvalidToken)
+	_, err = st.auth.Refresh(ctx, stolen.AccessToken, stolen.RefreshToken, nil)
+	wantErr(t, err, entity.ErrInvalidToken)
+	_, err = st.auth.Login(ctx, id, password, nil)
+	wantErr(t, err, entity.ErrUnauthenticated)
+
+	//Login with the new password in the same second gets tokens the watermark does not revoke.
+	issued, err := st.auth.Login(ctx, id, password+"!", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := st.auth.Validate(ctx, issued.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := st.now.Unix() + 1; claims.IssuedAt != want {
+		t.Errorf("got token issued at %d, want %d", claims.IssuedAt, want)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"example.com/auth-service-go/internal/entity"
+)
+
+//UserInfo are claims about the user returned by OpenID Connect userinfo endpoint.
+type UserInfo struct {
+	UserID entity.UserID
+	//UpdatedAt is a time user account was last updated at, it is zero if user has no account in this service.
+	UpdatedAt int64
+}
+
+//UserInfo returns claims about the user access token was issued for.
+//Only access tokens issued on behalf of a user are accepted, exchanged tokens must be issued for this service.
+func (s *AuthService) UserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+	if accessToken == "" {
+		return nil, entity.ErrUnauthenticated
+	}
+	claims, err := entity.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != "" && claims.Audience != s.issuer {
+		return nil, fmt.Errorf("%w: token is issued for another audience", entity.ErrInvalidToken)
+	}
+	if claims.User_id == "" {
+		return nil, fmt.Errorf("%w: token was not issued on behalf of a user", entity.ErrInvalidToken)
+	}
+	userID, err := claimsUserID(claims.User_id)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &UserInfo{UserID: userID}
+	//Users authenticated by API keys or upstream assertions have no account here.
+	user, err := s.users.Get(ctx, userID)
+	switch {
+	case err == nil:
+		info.UpdatedAt = user.UpdatedAt
+	case !errors.Is(err, entity.ErrUserNotFound):
+		return nil, err
+	}
+	return info, nil
+}
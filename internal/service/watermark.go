@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"example.com/auth-service-go/internal/entity"
+	"example.com/auth-service-go/internal/repository"
+)
+
+//SetNotBefore revokes all tokens of the user, or of everyone if user id is empty, issued before given time or in it`s second.
+//Zero time revokes tokens issued up to now. Watermark only moves forward and not into the future,
+//effective watermark is returned. Caller must have admin scope.
+func (s *AuthService) SetNotBefore(ctx context.Context, creds Credentials, userID entity.UserID, notBefore time.Time) (time.Time, error) {
+	now := s.now()
+	if notBefore.IsZero() {
+		notBefore = now
+	} else if notBefore.After(now) {
+		return time.Time{}, &entity.ArgumentError{Message: "Not before must not be in the future"}
+	}
+	principal, err := s.authorizeAdmin(ctx, creds)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	event := s.auditEvent(ctx, entity.AuditBulkRevoke, principal.UserID)
+	event.UserID = userID
+	event.Filter = &entity.AuditTokenFilter{IssuedBefore: notBefore.Unix()}
+	effective, err := s.watermarks.SetNotBefore(ctx, userID, notBefore.Unix())
+	if err != nil {
+		return time.Time{}, s.recordFailure(ctx, event, err)
+	}
+	event.Filter.IssuedBefore = effective
+	s.record(ctx, event)
+	return time.Unix(effective, 0), nil
+}
+
+//issueTime returns issue time of tokens of the user issued now. Watermark revokes tokens issued in it`s second too,
+//so tokens issued in the same second as the watermark of the user wait for the next second instead of being revoked
+//at once. The wait is shorter than a second and only happens right after revocation, e.g. login after password change.
+func (s *AuthService) issueTime(ctx context.Context, userID entity.UserID) (time.Time, error) {
+	now := s.now()
+	if userID == "" {
+		return now, nil
+	}
+	notBefore, err := s.watermarks.NotBefore(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if now.Unix() != notBefore {
+		return now, nil
+	}
+	next := time.Unix(notBefore+1, 0)
+	timer := time.NewTimer(next.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return next, nil
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+}
+
+//watermarkReadTimeout limits reading of watermarks, tokens are not accepted while storage does not answer.
+const watermarkReadTimeout = 2 * time.Second
+
+//WatermarkCache keeps watermarks read from storage for a short time, so checking tokens does not read storage every time.
+//Watermarks set through the cache take effect on this instance at once and on other instances once their cached values expire.
+type WatermarkCache struct {
+	watermarks repository.Watermark
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	entries   map[entity.UserID]cachedWatermark
+	lastSweep time.Time
+}
+
+type cachedWatermark struct {
+	notBefore int64
+	expiresAt time.Time
+}
+
+//NewWatermarkCache returns a new WatermarkCache keeping watermarks for given time.
+func NewWatermarkCache(watermarks repository.Watermark, ttl time.Duration) *WatermarkCache {
+	return &WatermarkCache{
+		watermarks: watermarks,
+		ttl:        ttl,
+		entries:    map[entity.UserID]cachedWatermark{},
+	}
+}
+
+//NotBefore returns the latest of the global watermark and the watermark of the user.
+func (c *WatermarkCache) NotBefore(ctx context.Context, userID entity.UserID) (int64, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && entry.expiresAt.After(now) {
+		return entry.notBefore, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, watermarkReadTimeout)
+	defer cancel()
+	notBefore, err := c.watermarks.NotBefore(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweep(now)
+	c.entries[userID] = cachedWatermark{notBefore: notBefore, expiresAt: now.Add(c.ttl)}
+	return notBefore, nil
+}
+
+//SetNotBefore sets the watermark in storage and forgets cached watermarks it applies to.
+func (c *WatermarkCache) SetNotBefore(ctx context.Context, userID entity.UserID, notBefore int64) (int64, error) {
+	effective, err := c.watermarks.SetNotBefore(ctx, userID, notBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if userID == "" {
+		c.entries = map[entity.UserID]cachedWatermark{}
+	} else {
+		delete(c.entries, userID)
+	}
+	return effective, nil
+}
+
+//sweep forgets expired watermarks, so memory is only held for recently checked users.
+func (c *WatermarkCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+	for userID, entry := range c.entries {
+		if !entry.expiresAt.After(now) {
+			delete(c.entries, userID)
+		}
+	}
+}
@@ -42,4 +42,17 @@ mongo <<EOF
      rs.status();
      use testTask;
      db.createCollection("tokens");
+     db.createCollection("users");
+     db.createCollection("clients");
+     db.createCollection("authorization_codes");
+     db.authorization_codes.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+     db.createCollection("device_codes");
+     db.device_codes.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+     db.device_codes.createIndex({ "user_code_hash": 1 }, { unique: true });
+     db.createCollection("rate_limits");
+     db.rate_limits.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+     db.createCollection("watermarks");
+     db.createCollection("audit_events");
+     db.audit_events.createIndex({ "time": 1, "_id": 1 });
+     db.audit_events.createIndex({ "user_id": 1, "time": 1 });
 EOF
\ No newline at end of file